@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ljinf/user_auth/api/request"
+	"github.com/ljinf/user_auth/common/app"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/logic/appservice"
+)
+
+// API Key 管理相关的Handler, 用户接口和管理员接口共用
+// 管理员接口的路径中携带 userId, 用户接口管理当前登录用户自己的API Key
+
+func CreateApiKey(c *gin.Context) {
+	uri, ok := bindApiKeyUri(c)
+	if !ok {
+		return
+	}
+	req := new(request.ApiKeyCreate)
+	if err := c.ShouldBindJSON(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	apiKey, err := appservice.NewApiKeyAppSvc(c).CreateApiKey(c.GetInt64("userId"), uri.UserId, req, clientInfo(c))
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(apiKey)
+}
+
+func ListApiKeys(c *gin.Context) {
+	uri, ok := bindApiKeyUri(c)
+	if !ok {
+		return
+	}
+	apiKeys, err := appservice.NewApiKeyAppSvc(c).ListApiKeys(uri.UserId)
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(apiKeys)
+}
+
+func RotateApiKey(c *gin.Context) {
+	uri, ok := bindApiKeyUri(c)
+	if !ok {
+		return
+	}
+	apiKey, err := appservice.NewApiKeyAppSvc(c).RotateApiKey(c.GetInt64("userId"), uri.UserId, uri.KeyId, clientInfo(c))
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(apiKey)
+}
+
+func RevokeApiKey(c *gin.Context) {
+	uri, ok := bindApiKeyUri(c)
+	if !ok {
+		return
+	}
+	err := appservice.NewApiKeyAppSvc(c).RevokeApiKey(c.GetInt64("userId"), uri.UserId, uri.KeyId, clientInfo(c))
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+// bindApiKeyUri 解析路径参数, 用户接口的路径中没有 userId, 使用当前登录的用户
+func bindApiKeyUri(c *gin.Context) (*request.ApiKeyUri, bool) {
+	uri := new(request.ApiKeyUri)
+	if err := c.ShouldBindUri(uri); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return nil, false
+	}
+	if uri.UserId == 0 {
+		uri.UserId = c.GetInt64("userId")
+	}
+	return uri, true
+}
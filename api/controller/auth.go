@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ljinf/user_auth/common/app"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/middleware"
+	"github.com/ljinf/user_auth/logic/appservice"
+	"net/http"
+	"strconv"
+)
+
+// ForwardAuth 提供给 Nginx auth_request 和 Traefik forwardAuth 使用的认证接口
+// 支持用户登录的AccessToken和API Key, 认证通过时响应200, 通过响应头把身份信息透传给上游服务:
+// X-Principal-Type 为 user 或 api_key, X-User-Id 为用户ID, 登录会话还有 X-Session-Id、X-Platform, API Key还有 X-Api-Key-Id
+// API Key和受限会话还会通过 X-Scope 透传允许的scope, 登录会话没有 X-Scope 响应头表示完整的登录会话
+// Token无效时响应401, 使用Cookie的原始请求未通过CSRF校验时响应403
+func ForwardAuth(c *gin.Context) {
+	token, source := middleware.ExtractToken(c)
+	if token == "" {
+		app.NewResponse(c).Error(errcode.ErrToken)
+		return
+	}
+	if source == middleware.TokenSourceCookie && !middleware.CheckCsrf(c, forwardedMethod(c)) {
+		app.NewResponse(c).Error(errcode.ErrCsrfTokenInvalid)
+		return
+	}
+	authReply, err := appservice.NewUserAppSvc(c).ForwardAuth(token)
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	c.Header("X-Principal-Type", authReply.PrincipalType)
+	c.Header("X-User-Id", strconv.FormatInt(authReply.UserId, 10))
+	if authReply.PrincipalType == enum.PrincipalTypeApiKey {
+		c.Header("X-Api-Key-Id", strconv.FormatInt(authReply.ApiKeyId, 10))
+	} else {
+		c.Header("X-Session-Id", authReply.SessionId)
+		c.Header("X-Platform", authReply.Platform)
+	}
+	if authReply.Scope != "" {
+		c.Header("X-Scope", authReply.Scope)
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+// forwardedMethod 网关转发认证请求时原始请求的方法, Traefik 使用 X-Forwarded-Method, Nginx 需要配置 X-Original-Method
+func forwardedMethod(c *gin.Context) string {
+	if method := c.Request.Header.Get("X-Forwarded-Method"); method != "" {
+		return method
+	}
+	if method := c.Request.Header.Get("X-Original-Method"); method != "" {
+		return method
+	}
+	return http.MethodGet
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ljinf/user_auth/api/request"
+	"github.com/ljinf/user_auth/common/app"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/logic/appservice"
+	"net/http"
+	"net/url"
+)
+
+// OAuth 2.0 相关的接口Handler
+// /oauth 下的协议接口按 RFC 6749 的格式直接响应, 不使用项目统一的响应结构
+
+func DeviceCode(c *gin.Context) {
+	req := new(request.DeviceCode)
+	if err := c.ShouldBind(req); err != nil {
+		oauthError(c, errcode.ErrOAuthInvalidRequest)
+		return
+	}
+	deviceCode, err := appservice.NewOAuthAppSvc(c).DeviceCode(req)
+	if err != nil {
+		oauthError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, deviceCode)
+}
+
+func OAuthToken(c *gin.Context) {
+	req := new(request.OAuthToken)
+	if err := c.ShouldBind(req); err != nil {
+		oauthError(c, errcode.ErrOAuthInvalidRequest)
+		return
+	}
+	if clientId, clientSecret, ok := c.Request.BasicAuth(); ok {
+		// 客户端凭证优先使用 HTTP Basic 认证传递, 参见 RFC 6749 2.3.1
+		// 凭证在Base64编码前按 application/x-www-form-urlencoded 编码过, 需要先解码
+		var err1, err2 error
+		req.ClientId, err1 = url.QueryUnescape(clientId)
+		req.ClientSecret, err2 = url.QueryUnescape(clientSecret)
+		if err1 != nil || err2 != nil {
+			oauthError(c, errcode.ErrOAuthInvalidClient)
+			return
+		}
+	}
+	token, err := appservice.NewOAuthAppSvc(c).Token(req, clientInfo(c))
+	if err != nil {
+		oauthError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, token)
+}
+
+// DeviceVerifyInfo 设备授权页面中用户输入 user_code 后查询申请授权的设备
+func DeviceVerifyInfo(c *gin.Context) {
+	req := new(request.DeviceVerifyQuery)
+	if err := c.ShouldBindQuery(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	info, err := appservice.NewOAuthAppSvc(c).DeviceVerifyInfo(req)
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(info)
+}
+
+// DeviceVerify 用户同意或拒绝设备的授权请求
+func DeviceVerify(c *gin.Context) {
+	req := new(request.DeviceVerify)
+	if err := c.ShouldBindJSON(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	err := appservice.NewOAuthAppSvc(c).DeviceVerify(c.GetInt64("userId"), req)
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+// oauthErrors 可以直接响应给客户端的 OAuth 协议错误, 这些错误码的Msg就是协议中的error值
+var oauthErrors = map[int]struct{}{
+	errcode.ErrOAuthInvalidRequest.Code():       {},
+	errcode.ErrOAuthInvalidClient.Code():        {},
+	errcode.ErrOAuthInvalidGrant.Code():         {},
+	errcode.ErrOAuthUnsupportedGrantType.Code(): {},
+	errcode.ErrOAuthInvalidScope.Code():         {},
+	errcode.ErrDeviceAuthPending.Code():         {},
+	errcode.ErrDeviceSlowDown.Code():            {},
+	errcode.ErrDeviceAccessDenied.Code():        {},
+	errcode.ErrDeviceCodeExpired.Code():         {},
+	errcode.ErrOAuthInvalidTarget.Code():        {},
+}
+
+// oauthError 按 RFC 6749 5.2 的格式响应错误, 其他错误一律按 server_error 响应
+func oauthError(c *gin.Context, err error) {
+	appErr, ok := err.(*errcode.AppError)
+	if ok {
+		_, ok = oauthErrors[appErr.Code()]
+	}
+	if !ok {
+		logger.New().Error(c, "oauth_response_error", "err", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(appErr.HttpStatusCode(), gin.H{"error": appErr.Msg()})
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ljinf/user_auth/api/reply"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/config"
+	"net/http"
+)
+
+// Web端的Cookie会话模式
+// 客户端在登录和刷新Token时携带请求头 go-mall-session-mode: cookie, 服务端把Token写入HttpOnly Cookie, 响应中不再返回Token
+
+const sessionModeHeader = "go-mall-session-mode"
+
+func sessionCookieMode(c *gin.Context) bool {
+	return config.SessionCookie != nil && config.SessionCookie.Enabled &&
+		c.Request.Header.Get(sessionModeHeader) == "cookie"
+}
+
+// setSessionCookies Cookie模式下把Token写入Cookie并清空响应中的Token, 同时下发双重提交校验使用的CSRF Token
+func setSessionCookies(c *gin.Context, token *reply.TokenReply) error {
+	if token == nil || !sessionCookieMode(c) {
+		return nil
+	}
+	csrfToken, err := util.SecureRandString(32, util.Alphanumeric)
+	if err != nil {
+		return err
+	}
+	cookieConf := config.SessionCookie
+	refreshMaxAge := int(enum.RefreshTokenDuration.Seconds())
+	c.SetSameSite(cookieSameSite(cookieConf.SameSite))
+	c.SetCookie(cookieConf.AccessCookie, token.AccessToken, int(token.Duration), "/", cookieConf.Domain, cookieConf.Secure, true)
+	c.SetCookie(cookieConf.RefreshCookie, token.RefreshToken, refreshMaxAge, cookieConf.RefreshPath, cookieConf.Domain, cookieConf.Secure, true)
+	// CSRF Token需要被页面JS读取, 不能设置HttpOnly
+	c.SetCookie(cookieConf.CsrfCookie, csrfToken, refreshMaxAge, "/", cookieConf.Domain, cookieConf.Secure, false)
+	token.AccessToken, token.RefreshToken = "", ""
+	return nil
+}
+
+func cookieSameSite(sameSite string) http.SameSite {
+	switch sameSite {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
@@ -0,0 +1,374 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/ljinf/user_auth/api/request"
+	"github.com/ljinf/user_auth/common/app"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/logic/appservice"
+	"io"
+	"net/http"
+	"time"
+)
+
+// 用户认证相关的接口Handler
+
+func SendSmsLoginCode(c *gin.Context) {
+	req := new(request.SmsCodeSend)
+	if err := c.ShouldBindJSON(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	err := appservice.NewUserAppSvc(c).SendSmsLoginCode(req)
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+func SmsCodeLogin(c *gin.Context) {
+	req := new(request.SmsCodeLogin)
+	if err := c.ShouldBindJSON(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	token, err := appservice.NewUserAppSvc(c).SmsCodeLogin(req, clientInfo(c))
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	if err = setSessionCookies(c, token); err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(token)
+}
+
+func SendEmailLoginCode(c *gin.Context) {
+	req := new(request.EmailCodeSend)
+	if err := c.ShouldBindJSON(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	err := appservice.NewUserAppSvc(c).SendEmailLoginCode(req, clientInfo(c))
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+func EmailCodeLogin(c *gin.Context) {
+	req := new(request.EmailCodeLogin)
+	if err := c.ShouldBindJSON(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	token, err := appservice.NewUserAppSvc(c).EmailCodeLogin(req, clientInfo(c))
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	if err = setSessionCookies(c, token); err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(token)
+}
+
+func SendVerifyEmailCode(c *gin.Context) {
+	err := appservice.NewUserAppSvc(c).SendVerifyEmailCode(c.GetInt64("userId"), clientInfo(c))
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+func VerifyEmail(c *gin.Context) {
+	req := new(request.EmailVerify)
+	if err := c.ShouldBindJSON(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	err := appservice.NewUserAppSvc(c).VerifyEmail(c.GetInt64("userId"), req)
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+// ForgotPassword 不管账号是否存在都返回成功
+func ForgotPassword(c *gin.Context) {
+	req := new(request.PasswordForgot)
+	if err := c.ShouldBindJSON(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	err := appservice.NewUserAppSvc(c).ForgotPassword(req, clientInfo(c))
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+func ResetPassword(c *gin.Context) {
+	req := new(request.PasswordReset)
+	if err := c.ShouldBindJSON(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	err := appservice.NewUserAppSvc(c).ResetPassword(req, clientInfo(c))
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+// SendSetPasswordCode 还没有设置过密码的用户设置密码前获取验证码
+func SendSetPasswordCode(c *gin.Context) {
+	err := appservice.NewUserAppSvc(c).SendSetPasswordCode(c.GetInt64("userId"), clientInfo(c))
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+func ChangePassword(c *gin.Context) {
+	req := new(request.PasswordChange)
+	if err := c.ShouldBindJSON(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	err := appservice.NewUserAppSvc(c).ChangePassword(c.GetInt64("userId"), c.GetString("sessionId"), req, clientInfo(c))
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+// TokenRefresh 刷新Token, Cookie模式下从Cookie中读取RefreshToken并把新的Token写回Cookie
+func TokenRefresh(c *gin.Context) {
+	req := new(request.TokenRefresh)
+	// Cookie模式下请求体可以为空
+	if err := c.ShouldBindJSON(req); err != nil && !errors.Is(err, io.EOF) {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	if req.RefreshToken == "" && sessionCookieMode(c) {
+		req.RefreshToken, _ = c.Cookie(config.SessionCookie.RefreshCookie)
+	}
+	if req.RefreshToken == "" {
+		app.NewResponse(c).Error(errcode.ErrParams)
+		return
+	}
+	token, err := appservice.NewUserAppSvc(c).TokenRefresh(req.RefreshToken)
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	if err = setSessionCookies(c, token); err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(token)
+}
+
+// magicLinkDeviceCookie 邮件登录链接绑定浏览器使用的Cookie
+const magicLinkDeviceCookie = "go-mall-magic-link"
+
+func SendMagicLink(c *gin.Context) {
+	req := new(request.MagicLinkSend)
+	if err := c.ShouldBindJSON(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	deviceBinding, err := appservice.NewUserAppSvc(c).SendMagicLink(req, clientInfo(c))
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	if deviceBinding != "" {
+		// 与会话Cookie使用相同的Domain和Secure配置, 本地HTTP开发环境也能完成设备绑定
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(magicLinkDeviceCookie, deviceBinding, int(config.MagicLink.TTL.Seconds()), "/",
+			config.SessionCookie.Domain, config.SessionCookie.Secure, true)
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+func MagicLinkLogin(c *gin.Context) {
+	req := new(request.MagicLinkLogin)
+	if err := c.ShouldBindJSON(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	deviceBinding, _ := c.Cookie(magicLinkDeviceCookie)
+	token, err := appservice.NewUserAppSvc(c).MagicLinkLogin(req, deviceBinding, clientInfo(c))
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	// 链接已被使用, 清除设备绑定的Cookie
+	c.SetCookie(magicLinkDeviceCookie, "", -1, "/", config.SessionCookie.Domain, config.SessionCookie.Secure, true)
+	if err = setSessionCookies(c, token); err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(token)
+}
+
+func CreateQrTicket(c *gin.Context) {
+	ticket, err := appservice.NewUserAppSvc(c).CreateQrTicket()
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(ticket)
+}
+
+// QrTicketStatus PC端轮询二维码状态
+func QrTicketStatus(c *gin.Context) {
+	req := new(request.QrTicketPoll)
+	if err := c.ShouldBindQuery(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	status, err := appservice.NewUserAppSvc(c).PollQrTicket(req, clientInfo(c))
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	if err = setSessionCookies(c, status.Token); err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(status)
+}
+
+// QrTicketStream 通过SSE向PC端推送二维码状态的变化, 二维码确认或者过期后结束推送
+// 推送开始后不能再写入Cookie, 这里只推送状态, 状态为 confirmed 后PC端通过 QrTicketStatus 拿到Token
+func QrTicketStream(c *gin.Context) {
+	req := new(request.QrTicketPoll)
+	if err := c.ShouldBindQuery(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	userAppSvc := appservice.NewUserAppSvc(c)
+	ticker := time.NewTicker(config.QrLogin.StreamInterval)
+	defer ticker.Stop()
+	timeout := time.After(config.QrLogin.TTL)
+	lastStatus := ""
+	c.Stream(func(w io.Writer) bool {
+		status, err := userAppSvc.QrTicketStatus(req)
+		if err != nil {
+			appErr, ok := err.(*errcode.AppError)
+			if !ok || appErr.Code() == -1 {
+				logger.New().Error(c, "QrTicketStatusErr", "err", err)
+				appErr = errcode.ErrServer
+			}
+			c.SSEvent("error", gin.H{"code": appErr.Code(), "msg": appErr.Msg()})
+			return false
+		}
+		if status.Status != lastStatus {
+			lastStatus = status.Status
+			c.SSEvent("status", status)
+		}
+		if status.Status == enum.QrTicketStatusConfirmed || status.Status == enum.QrTicketStatusExpired {
+			return false
+		}
+		select {
+		case <-ticker.C:
+			return true
+		case <-timeout:
+			return false
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// ScanQrTicket App扫描PC端的二维码
+func ScanQrTicket(c *gin.Context) {
+	req := new(request.QrTicketScan)
+	if err := c.ShouldBindJSON(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	err := appservice.NewUserAppSvc(c).ScanQrTicket(c.GetInt64("userId"), req)
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+// ConfirmQrTicket App确认登录PC端
+func ConfirmQrTicket(c *gin.Context) {
+	req := new(request.QrTicketScan)
+	if err := c.ShouldBindJSON(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	err := appservice.NewUserAppSvc(c).ConfirmQrTicket(c.GetInt64("userId"), req)
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).SuccessOk()
+}
+
+// clientInfo 收集发起请求的客户端信息, 设备标识由客户端通过 go-mall-device-id Header 传递
+func clientInfo(c *gin.Context) *request.ClientInfo {
+	return &request.ClientInfo{
+		Ip:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		DeviceId:  c.Request.Header.Get("go-mall-device-id"),
+		Locale:    c.Request.Header.Get("Accept-Language"),
+	}
+}
+
+// responseError 预定义的错误直接响应给客户端, Wrap生成的错误一律按服务器内部错误响应
+func responseError(c *gin.Context, err error) {
+	appErr, ok := err.(*errcode.AppError)
+	if !ok || appErr.Code() == -1 {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+	app.NewResponse(c).Error(appErr)
+}
+
+// GetCurrentUserInfo 查询当前用户的基本信息, API Key和受限会话需要 user.read
+func GetCurrentUserInfo(c *gin.Context) {
+	userInfo, err := appservice.NewUserAppSvc(c).GetUserBaseInfo(c.GetInt64("userId"))
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(userInfo)
+}
+
+// GetUserBaseInfo 内部服务查询用户的基本信息
+func GetUserBaseInfo(c *gin.Context) {
+	req := new(request.UserUri)
+	if err := c.ShouldBindUri(req); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	userInfo, err := appservice.NewUserAppSvc(c).GetUserBaseInfo(req.UserId)
+	if err != nil {
+		responseError(c, err)
+		return
+	}
+	app.NewResponse(c).Success(userInfo)
+}
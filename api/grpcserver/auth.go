@@ -0,0 +1,86 @@
+package grpcserver
+
+import (
+	"context"
+	"github.com/ljinf/user_auth/api/proto/authpb"
+	"github.com/ljinf/user_auth/api/reply"
+	"github.com/ljinf/user_auth/logic/appservice"
+)
+
+// authService 实现 authpb.AuthServiceServer, 与HTTP的Controller一样只做参数和响应的转换
+type authService struct {
+	authpb.UnimplementedAuthServiceServer
+}
+
+func (s *authService) VerifyAccessToken(ctx context.Context, req *authpb.VerifyAccessTokenRequest) (*authpb.VerifyAccessTokenResponse, error) {
+	tokenVerify, err := appservice.NewAuthAppSvc(ctx).VerifyAccessToken(req.GetAccessToken())
+	if err != nil {
+		return nil, err
+	}
+	return &authpb.VerifyAccessTokenResponse{
+		Approved:  tokenVerify.Approved,
+		UserId:    tokenVerify.UserId,
+		SessionId: tokenVerify.SessionId,
+		Platform:  tokenVerify.Platform,
+		Scope:     tokenVerify.Scope,
+	}, nil
+}
+
+func (s *authService) GetUserBaseInfo(ctx context.Context, req *authpb.GetUserBaseInfoRequest) (*authpb.UserBaseInfo, error) {
+	userInfo, err := appservice.NewAuthAppSvc(ctx).GetUserBaseInfo(req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+	return toUserBaseInfoPb(userInfo), nil
+}
+
+func (s *authService) BatchGetUserBaseInfo(ctx context.Context, req *authpb.BatchGetUserBaseInfoRequest) (*authpb.BatchGetUserBaseInfoResponse, error) {
+	userInfos, err := appservice.NewAuthAppSvc(ctx).BatchGetUserBaseInfo(req.GetUserIds())
+	if err != nil {
+		return nil, err
+	}
+	resp := &authpb.BatchGetUserBaseInfoResponse{Users: make([]*authpb.UserBaseInfo, 0, len(userInfos))}
+	for _, userInfo := range userInfos {
+		resp.Users = append(resp.Users, toUserBaseInfoPb(userInfo))
+	}
+	return resp, nil
+}
+
+func (s *authService) RevokeSession(ctx context.Context, req *authpb.RevokeSessionRequest) (*authpb.RevokeSessionResponse, error) {
+	err := appservice.NewAuthAppSvc(ctx).RevokeSession(req.GetUserId(), req.GetSessionId())
+	if err != nil {
+		return nil, err
+	}
+	return &authpb.RevokeSessionResponse{}, nil
+}
+
+func (s *authService) Introspect(ctx context.Context, req *authpb.IntrospectRequest) (*authpb.IntrospectResponse, error) {
+	// 交换得到的Token只对它的目标服务有效, 目标服务取认证得到的调用方, 而不是调用方在请求中声明的服务
+	introspection, err := appservice.NewAuthAppSvc(ctx).Introspect(req.GetToken(), callerService(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return &authpb.IntrospectResponse{
+		Active:    introspection.Active,
+		TokenType: introspection.TokenType,
+		UserId:    introspection.UserId,
+		SessionId: introspection.SessionId,
+		Platform:  introspection.Platform,
+		Scope:     introspection.Scope,
+		Audience:  introspection.Audience,
+		Actors:    introspection.Actors,
+	}, nil
+}
+
+func toUserBaseInfoPb(userInfo *reply.UserBaseInfoReply) *authpb.UserBaseInfo {
+	return &authpb.UserBaseInfo{
+		Id:        userInfo.Id,
+		Nickname:  userInfo.Nickname,
+		LoginName: userInfo.LoginName,
+		Verified:  int32(userInfo.Verified),
+		Avatar:    userInfo.Avatar,
+		Slogan:    userInfo.Slogan,
+		IsBlocked: int32(userInfo.IsBlocked),
+		CreatedAt: userInfo.CreatedAt.Unix(),
+	}
+}
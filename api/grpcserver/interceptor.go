@@ -0,0 +1,162 @@
+package grpcserver
+
+import (
+	"context"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/library"
+	"github.com/ljinf/user_auth/logic/domainservice"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// gRPC服务的拦截器, 与HTTP服务的基础中间件对应
+
+// startTrace 从metadata中读取追踪信息, 使用与 middleware.StartTrace 相同的 traceid、spanid
+func startTrace(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var traceId, pSpanId, addr string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		traceId = firstMetadata(md, "traceid")
+		pSpanId = firstMetadata(md, "spanid")
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+	}
+	spanId := util.GenerateSpanID(addr)
+	if traceId == "" {
+		// 如果traceId 为空，证明是链路的发端，把它设置成此次的spanId
+		traceId = spanId
+	}
+	ctx = context.WithValue(ctx, "traceid", traceId)
+	ctx = context.WithValue(ctx, "spanid", spanId)
+	ctx = context.WithValue(ctx, "pspanid", pSpanId)
+	return handler(ctx, req)
+}
+
+func logAccess(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	logger.New().Info(ctx, "GrpcAccessLog",
+		"method", info.FullMethod,
+		"code", status.Code(err).String(),
+		"time(ms)", int64(time.Since(start)/time.Millisecond))
+	return resp, err
+}
+
+func panicRecovery(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.New().Error(ctx, "grpc_request_panic", "method", info.FullMethod, "error", r, "stack", string(debug.Stack()))
+			err = errcode.ErrPanic
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// mapError 把 errcode.AppError 转换成 gRPC status, 原始的错误码放在 ErrorInfo 中
+func mapError(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err == nil {
+		return resp, nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return resp, err
+	}
+	appErr, ok := err.(*errcode.AppError)
+	if !ok || appErr.Code() == -1 {
+		logger.New().Error(ctx, "grpc_response_error", "method", info.FullMethod, "err", err)
+		appErr = errcode.ErrServer
+	}
+	st := status.New(grpcCode(appErr), appErr.Msg())
+	if detailed, detailErr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: strconv.Itoa(appErr.Code()),
+		Domain: "user_auth",
+	}); detailErr == nil {
+		st = detailed
+	}
+	return resp, st.Err()
+}
+
+// grpcCode 按错误码对应的HTTP状态码转换成 gRPC 状态码
+func grpcCode(appErr *errcode.AppError) codes.Code {
+	switch appErr.HttpStatusCode() {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	default:
+		return codes.Internal
+	}
+}
+
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// callerServiceKey Context中保存调用方服务名的key
+type callerServiceKey struct{}
+
+// authCaller 认证调用方的服务身份, 与HTTP服务的 middleware.AuthService 对应
+// 调用方提供了配置中的客户端证书(mTLS)时直接通过, 否则校验metadata中的请求签名, 两者都没有时拒绝调用
+// 签名的请求方法固定为POST, 请求路径为RPC的完整方法名, 请求体为请求消息的确定性序列化结果
+func authCaller(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			if service := library.CertPrincipal(&tlsInfo.State); service != "" {
+				return handler(context.WithValue(ctx, callerServiceKey{}, service), req)
+			}
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if firstMetadata(md, util.SignHeaderSignature) == "" {
+		return nil, status.Error(codes.Unauthenticated, "client certificate or request signature required")
+	}
+	message, ok := req.(proto.Message)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unsupported request message")
+	}
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid request message")
+	}
+	signKey, err := domainservice.NewRequestSignDomainSvc(ctx).Verify(&domainservice.SignedRequest{
+		KeyId:      firstMetadata(md, util.SignHeaderKeyId),
+		Timestamp:  firstMetadata(md, util.SignHeaderTimestamp),
+		Nonce:      firstMetadata(md, util.SignHeaderNonce),
+		Signature:  firstMetadata(md, util.SignHeaderSignature),
+		Method:     http.MethodPost,
+		RequestUri: info.FullMethod,
+		Body:       body,
+	})
+	if err != nil {
+		// 交给 mapError 转换, 签名错误对应 codes.Unauthenticated
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, callerServiceKey{}, signKey.Service), req)
+}
+
+// callerService 返回 authCaller 认证得到的调用方服务名
+func callerService(ctx context.Context) string {
+	service, _ := ctx.Value(callerServiceKey{}).(string)
+	return service
+}
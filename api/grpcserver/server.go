@@ -0,0 +1,26 @@
+package grpcserver
+
+import (
+	"github.com/ljinf/user_auth/api/proto/authpb"
+	"github.com/ljinf/user_auth/library"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// NewServer 创建提供给内部服务的gRPC服务, 开启了 server_tls 时使用同样的证书, 校验调用方提供的客户端证书
+// 每个调用都需要通过客户端证书或者请求签名认证调用方的服务身份
+func NewServer() (*grpc.Server, error) {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(startTrace, logAccess, mapError, panicRecovery, authCaller),
+	}
+	tlsConfig, err := library.NewServerTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	server := grpc.NewServer(opts...)
+	authpb.RegisterAuthServiceServer(server, new(authService))
+	return server, nil
+}
@@ -0,0 +1,22 @@
+package reply
+
+// TokenVerifyReply 内部服务验证AccessToken的结果
+type TokenVerifyReply struct {
+	Approved  bool
+	UserId    int64
+	SessionId string
+	Platform  string
+	Scope     string
+}
+
+// IntrospectReply Token的详细信息, Actors 是代表用户发起调用的服务链条, 最近的调用方在前
+type IntrospectReply struct {
+	Active    bool
+	TokenType string
+	UserId    int64
+	SessionId string
+	Platform  string
+	Scope     string
+	Audience  string
+	Actors    []string
+}
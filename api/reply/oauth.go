@@ -0,0 +1,29 @@
+package reply
+
+type DeviceCodeReply struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationUri         string `json:"verification_uri"`
+	VerificationUriComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// DeviceVerifyReply 在设备授权页面展示给用户的信息
+type DeviceVerifyReply struct {
+	ClientId   string `json:"client_id"`
+	ClientName string `json:"client_name"`
+	Scope      string `json:"scope"`
+	UserCode   string `json:"user_code"`
+}
+
+// OAuthTokenReply RFC 6749 5.1 定义的Token响应
+type OAuthTokenReply struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	// Token交换时返回, 参见 RFC 8693 2.2.1
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+}
@@ -1,8 +1,54 @@
 package reply
 
+import "time"
+
 type TokenReply struct {
 	AccessToken   string `json:"access_token"`
 	RefreshToken  string `json:"refresh_token"`
 	Duration      int64  `json:"duration"`
 	SrvCreateTime string `json:"srv_create_time"`
 }
+
+type QrTicketReply struct {
+	Ticket    string `json:"ticket"` // 二维码的内容
+	Secret    string `json:"secret"` // PC端查询二维码状态时需要携带
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+type QrTicketStatusReply struct {
+	Status string      `json:"status"`          // pending, scanned, confirmed, expired
+	Token  *TokenReply `json:"token,omitempty"` // 状态为confirmed时返回
+}
+
+// ApiKeyReply API Key信息, Key只在创建和轮换时返回一次
+type ApiKeyReply struct {
+	Id        int64      `json:"id"`
+	Name      string     `json:"name"`
+	KeyPrefix string     `json:"key_prefix"`
+	Key       string     `json:"key,omitempty"`
+	Scope     string     `json:"scope"`
+	ExpiredAt *time.Time `json:"expired_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// UserBaseInfoReply 提供给内部服务查询的用户基本信息
+type UserBaseInfoReply struct {
+	Id        int64     `json:"id"`
+	Nickname  string    `json:"nickname"`
+	LoginName string    `json:"login_name"`
+	Verified  int       `json:"verified"`
+	Avatar    string    `json:"avatar"`
+	Slogan    string    `json:"slogan"`
+	IsBlocked int       `json:"is_blocked"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ForwardAuthReply 网关转发认证通过后写入响应头的用户信息
+type ForwardAuthReply struct {
+	PrincipalType string
+	UserId        int64
+	SessionId     string
+	Platform      string
+	ApiKeyId      int64
+	Scope         string
+}
@@ -0,0 +1,29 @@
+package request
+
+// OAuth 2.0 协议接口的请求参数, 按 RFC 6749 使用 application/x-www-form-urlencoded 格式提交
+
+type DeviceCode struct {
+	ClientId string `form:"client_id" binding:"required"`
+	Scope    string `form:"scope"`
+}
+
+type OAuthToken struct {
+	GrantType  string `form:"grant_type" binding:"required"`
+	ClientId   string `form:"client_id"`
+	DeviceCode string `form:"device_code"` // grant_type 为 device_code 时使用
+	// 以下参数在 grant_type 为 token-exchange 时使用, 参见 RFC 8693 2.1
+	ClientSecret     string `form:"client_secret"` // 也可以通过 HTTP Basic 认证传递
+	SubjectToken     string `form:"subject_token"`
+	SubjectTokenType string `form:"subject_token_type"`
+	Audience         string `form:"audience"`
+	Scope            string `form:"scope"`
+}
+
+type DeviceVerifyQuery struct {
+	UserCode string `form:"user_code" binding:"required"`
+}
+
+type DeviceVerify struct {
+	UserCode string `json:"user_code" binding:"required"`
+	Approve  bool   `json:"approve"` // true-同意授权, false-拒绝
+}
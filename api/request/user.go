@@ -0,0 +1,88 @@
+package request
+
+import "time"
+
+// ClientInfo 发起请求的客户端的信息, 由controller从请求中收集, 登录风控等逻辑会用到
+type ClientInfo struct {
+	Ip        string
+	UserAgent string
+	DeviceId  string
+	Locale    string // 客户端的 Accept-Language, 用于选择邮件模板的语言
+}
+
+type SmsCodeSend struct {
+	Phone string `json:"phone" binding:"required,numeric"`
+}
+
+type SmsCodeLogin struct {
+	Phone    string `json:"phone" binding:"required,numeric"`
+	Code     string `json:"code" binding:"required,numeric"`
+	Platform string `json:"platform" binding:"required,oneof=app h5 pc wx"`
+}
+
+type EmailCodeSend struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type EmailCodeLogin struct {
+	Email    string `json:"email" binding:"required,email"`
+	Code     string `json:"code" binding:"required,numeric"`
+	Platform string `json:"platform" binding:"required,oneof=app h5 pc wx"`
+}
+
+type EmailVerify struct {
+	Code string `json:"code" binding:"required,numeric"`
+}
+
+type PasswordForgot struct {
+	Account string `json:"account" binding:"required"` // 邮箱或手机号
+}
+
+type PasswordReset struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"` // 密码强度由密码策略校验
+}
+
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password"`                // 还没有设置过密码的用户可以不传
+	NewPassword     string `json:"new_password" binding:"required"` // 密码强度由密码策略校验
+	LogoutOthers    bool   `json:"logout_others"`                   // 是否让其他平台的登录状态失效, 当前的登录状态始终保留
+	Code            string `json:"code"`                            // 还没有设置过密码的用户需要传发送到手机号或邮箱的验证码
+}
+
+type MagicLinkSend struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type MagicLinkLogin struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type QrTicketPoll struct {
+	Ticket string `form:"ticket" binding:"required"`
+	Secret string `form:"secret" binding:"required"`
+}
+
+type QrTicketScan struct {
+	Ticket string `json:"ticket" binding:"required"`
+}
+
+type ApiKeyCreate struct {
+	Name      string     `json:"name" binding:"required,max=64"`
+	Scope     string     `json:"scope"`      // 空格分隔, 为空时不授予任何scope
+	ExpiredAt *time.Time `json:"expired_at"` // 为空时永不过期
+}
+
+// ApiKeyUri 管理API Key接口路径中的参数, 管理员接口会额外携带 userId
+type ApiKeyUri struct {
+	UserId int64 `uri:"userId"`
+	KeyId  int64 `uri:"keyId"`
+}
+
+type TokenRefresh struct {
+	RefreshToken string `json:"refresh_token"` // 使用Cookie模式时从Cookie中读取
+}
+
+type UserUri struct {
+	UserId int64 `uri:"userId" binding:"required"`
+}
@@ -0,0 +1,19 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ljinf/user_auth/api/controller"
+	"github.com/ljinf/user_auth/common/middleware"
+)
+
+// 管理员使用的路由
+
+func registerAdminRoutes(rg *gin.RouterGroup) {
+	// 这个路由组中的路由都以 /admin 开头
+	g := rg.Group("/admin/", middleware.AuthUser(), middleware.RequireSession(), middleware.AdminUser())
+	// 管理用户的API Key
+	g.GET("users/:userId/api-keys", controller.ListApiKeys)
+	g.POST("users/:userId/api-keys", controller.CreateApiKey)
+	g.POST("users/:userId/api-keys/:keyId/rotate", controller.RotateApiKey)
+	g.DELETE("users/:userId/api-keys/:keyId", controller.RevokeApiKey)
+}
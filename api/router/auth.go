@@ -0,0 +1,15 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ljinf/user_auth/api/controller"
+)
+
+// 提供给网关使用的认证路由
+
+func registerAuthRoutes(rg *gin.RouterGroup) {
+	// 这个路由组中的路由都以 /auth 开头
+	g := rg.Group("/auth/")
+	// 网关转发认证 Nginx auth_request / Traefik forwardAuth
+	g.GET("verify", controller.ForwardAuth)
+}
@@ -0,0 +1,16 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ljinf/user_auth/api/controller"
+	"github.com/ljinf/user_auth/common/middleware"
+)
+
+// 提供给内部服务调用的路由, 调用方需要对请求签名
+
+func registerInternalRoutes(rg *gin.RouterGroup) {
+	// 这个路由组中的路由都以 /internal 开头
+	g := rg.Group("/internal/", middleware.AuthService())
+	// 查询用户的基本信息
+	g.GET("users/:userId", controller.GetUserBaseInfo)
+}
@@ -0,0 +1,22 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ljinf/user_auth/api/controller"
+	"github.com/ljinf/user_auth/common/middleware"
+)
+
+// OAuth 2.0 相关的路由
+
+func registerOAuthRoutes(rg *gin.RouterGroup) {
+	// 这个路由组中的路由都以 /oauth 开头
+	g := rg.Group("/oauth/")
+	// 设备申请授权 RFC 8628
+	g.POST("device/code", controller.DeviceCode)
+	// 获取Token
+	g.POST("token", controller.OAuthToken)
+	// 设备授权页面: 查询 user_code 对应的授权请求
+	g.GET("device/verify", middleware.AuthUser(), middleware.RequireSession(), controller.DeviceVerifyInfo)
+	// 设备授权页面: 同意或拒绝授权
+	g.POST("device/verify", middleware.AuthUser(), middleware.RequireSession(), controller.DeviceVerify)
+}
@@ -3,6 +3,7 @@ package router
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/ljinf/user_auth/common/middleware"
+	"github.com/ljinf/user_auth/config"
 )
 
 func RegisterRoutes(engine *gin.Engine) {
@@ -10,4 +11,18 @@ func RegisterRoutes(engine *gin.Engine) {
 	engine.Use(middleware.StartTrace(), middleware.LogAccess(), middleware.GinPanicRecovery())
 	routeGroup := engine.Group("")
 	registerBuildingRoutes(routeGroup)
+	registerUserRoutes(routeGroup)
+	registerOAuthRoutes(routeGroup)
+	registerAdminRoutes(routeGroup)
+	registerAuthRoutes(routeGroup)
+	// 配置了内部接口的mTLS监听地址时, 内部接口只在那个地址上提供
+	if config.ServerTLS == nil || !config.ServerTLS.Enabled || config.ServerTLS.InternalAddr == "" {
+		registerInternalRoutes(routeGroup)
+	}
+}
+
+// RegisterInternalRoutes 注册内部接口的mTLS监听地址上提供的路由
+func RegisterInternalRoutes(engine *gin.Engine) {
+	engine.Use(middleware.StartTrace(), middleware.LogAccess(), middleware.GinPanicRecovery())
+	registerInternalRoutes(engine.Group(""))
 }
@@ -0,0 +1,58 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ljinf/user_auth/api/controller"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/middleware"
+)
+
+// 用户认证相关的路由
+
+func registerUserRoutes(rg *gin.RouterGroup) {
+	// 这个路由组中的路由都以 /user 开头
+	g := rg.Group("/user/")
+	// 查询当前用户的基本信息, 机器客户端可以调用
+	g.GET("info", middleware.AuthUser(), middleware.RequireScope(enum.ScopeUserRead), controller.GetCurrentUserInfo)
+	// 发送短信登录验证码
+	g.POST("sms-code/send", controller.SendSmsLoginCode)
+	// 短信验证码登录
+	g.POST("login/sms", controller.SmsCodeLogin)
+	// 发送邮件登录验证码
+	g.POST("email-code/send", controller.SendEmailLoginCode)
+	// 邮件验证码登录
+	g.POST("login/email", controller.EmailCodeLogin)
+	// 发送邮箱验证的验证码
+	g.POST("email/verify-code/send", middleware.AuthUser(), middleware.RequireSession(), controller.SendVerifyEmailCode)
+	// 验证邮箱
+	g.POST("email/verify", middleware.AuthUser(), middleware.RequireSession(), controller.VerifyEmail)
+	// 刷新Token, 使用Cookie模式时需要通过CSRF校验
+	g.POST("token/refresh", middleware.CsrfProtect(), controller.TokenRefresh)
+	// 找回密码
+	g.POST("password/forgot", controller.ForgotPassword)
+	// 通过找回密码链接重置密码
+	g.POST("password/reset", controller.ResetPassword)
+	// 发送H5邮件登录链接
+	g.POST("magic-link/send", controller.SendMagicLink)
+	// 使用邮件登录链接登录H5
+	g.POST("login/magic-link", controller.MagicLinkLogin)
+	// PC端申请扫码登录的二维码
+	g.POST("qr-login/ticket", controller.CreateQrTicket)
+	// PC端轮询二维码状态
+	g.GET("qr-login/ticket/status", controller.QrTicketStatus)
+	// PC端通过SSE接收二维码状态
+	g.GET("qr-login/ticket/stream", controller.QrTicketStream)
+	// App扫描二维码
+	g.POST("qr-login/scan", middleware.AuthUser(), middleware.RequireSession(), controller.ScanQrTicket)
+	// App确认登录PC端
+	g.POST("qr-login/confirm", middleware.AuthUser(), middleware.RequireSession(), controller.ConfirmQrTicket)
+	// 修改密码, 还没有设置过密码的用户需要先获取验证码
+	g.POST("password/code/send", middleware.AuthUser(), middleware.RequireSession(), controller.SendSetPasswordCode)
+	g.POST("password", middleware.AuthUser(), middleware.RequireSession(), controller.ChangePassword)
+	// 管理自己的API Key, 不能使用API Key管理API Key
+	apiKeys := g.Group("api-keys", middleware.AuthUser(), middleware.RequireSession())
+	apiKeys.GET("", controller.ListApiKeys)
+	apiKeys.POST("", controller.CreateApiKey)
+	apiKeys.POST("/:keyId/rotate", controller.RotateApiKey)
+	apiKeys.DELETE("/:keyId", controller.RevokeApiKey)
+}
@@ -0,0 +1,168 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/cache"
+	"github.com/ljinf/user_auth/dal/dao"
+	"github.com/ljinf/user_auth/library"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 应用启动时按 配置 → 日志 → 数据库 → Redis → 会话存储 的顺序初始化各个组件
+// 通过Option传入的组件不再按配置创建, 测试中可以用它传入内存实现
+
+type bootstrapOption struct {
+	configFile string
+	logger     *zap.Logger
+	dbMaster   *gorm.DB
+	dbSlave    *gorm.DB
+	redis      redis.UniversalClient
+	sessions   cache.SessionStore
+}
+
+type Option interface {
+	apply(option *bootstrapOption)
+}
+
+type optionFunc func(option *bootstrapOption)
+
+func (f optionFunc) apply(opts *bootstrapOption) {
+	f(opts)
+}
+
+// WithConfigFile 指定配置文件, 不指定时按环境变量 ENV 读取 ./config 目录下的配置
+func WithConfigFile(file string) Option {
+	return optionFunc(func(opts *bootstrapOption) {
+		opts.configFile = file
+	})
+}
+
+// WithLogger 使用传入的zap日志
+func WithLogger(l *zap.Logger) Option {
+	return optionFunc(func(opts *bootstrapOption) {
+		opts.logger = l
+	})
+}
+
+// WithDB 使用传入的数据库实例, 没有读写分离时 slave 可以传nil
+func WithDB(master, slave *gorm.DB) Option {
+	return optionFunc(func(opts *bootstrapOption) {
+		if slave == nil {
+			slave = master
+		}
+		opts.dbMaster = master
+		opts.dbSlave = slave
+	})
+}
+
+// WithRedis 使用传入的Redis客户端
+func WithRedis(client redis.UniversalClient) Option {
+	return optionFunc(func(opts *bootstrapOption) {
+		opts.redis = client
+	})
+}
+
+// WithSessionStore 使用传入的会话存储, 不再按 auth.session_store 配置创建
+func WithSessionStore(store cache.SessionStore) Option {
+	return optionFunc(func(opts *bootstrapOption) {
+		opts.sessions = store
+	})
+}
+
+// Bootstrap 初始化应用依赖的组件, 返回的 shutdown 关闭由Bootstrap创建的连接池并写入缓冲中的日志
+// 任一组件初始化失败时会关闭已经创建的组件并返回错误
+func Bootstrap(ctx context.Context, options ...Option) (shutdown func() error, err error) {
+	opts := &bootstrapOption{}
+	for _, opt := range options {
+		opt.apply(opts)
+	}
+
+	var closers []func() error
+	closeAll := func() error {
+		var errs []error
+		for i := len(closers) - 1; i >= 0; i-- {
+			if err := closers[i](); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+	defer func() {
+		if err != nil {
+			closeAll()
+		}
+	}()
+
+	if err = config.Load(opts.configFile); err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+
+	if opts.logger != nil {
+		logger.SetLogger(opts.logger)
+	} else {
+		if err = logger.Init(); err != nil {
+			return nil, err
+		}
+		closers = append(closers, func() error {
+			// 输出到控制台时 Sync 会返回 invalid argument 之类的错误, 忽略即可
+			logger.Sync()
+			return nil
+		})
+	}
+
+	if opts.dbMaster != nil {
+		dao.SetDB(opts.dbMaster, opts.dbSlave)
+	} else {
+		if err = dao.Init(ctx); err != nil {
+			return nil, err
+		}
+		closers = append(closers, dao.Close)
+	}
+
+	if opts.redis != nil {
+		cache.SetRedis(opts.redis)
+	} else {
+		if err = cache.Init(ctx); err != nil {
+			if config.Auth.SessionStore == "memory" {
+				// 会话存储在内存中时其他功能仍然依赖Redis, 启动时就失败, 不要等到请求时才出错
+				return nil, fmt.Errorf("redis: session_store memory only keeps sessions in memory, "+
+					"verify codes, rate limits, api key cache, request nonces and revocation events still need redis: %w", err)
+			}
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		closers = append(closers, cache.Close)
+	}
+
+	if opts.sessions == nil {
+		if opts.sessions, err = newSessionStore(); err != nil {
+			return nil, err
+		}
+	}
+	cache.SetSessionStore(opts.sessions)
+
+	// 通知渠道配置错误时启动失败, 而不是等到发送短信、邮件时才发现
+	if _, err = library.NewSmsSender(); err != nil {
+		return nil, err
+	}
+	if _, err = library.NewMailSender(); err != nil {
+		return nil, err
+	}
+	return closeAll, nil
+}
+
+func newSessionStore() (cache.SessionStore, error) {
+	switch config.Auth.SessionStore {
+	case "", "redis":
+		return cache.NewRedisSessionStore(cache.Redis()), nil
+	case "memory":
+		return cache.NewMemorySessionStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown session_store: %s", config.Auth.SessionStore)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/ljinf/user_auth/api/grpcserver"
+	"github.com/ljinf/user_auth/api/router"
+	"github.com/ljinf/user_auth/bootstrap"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/library"
+	"github.com/ljinf/user_auth/logic/appservice"
+	"google.golang.org/grpc"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	ctx := newServerContext()
+	shutdown, err := bootstrap.Bootstrap(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bootstrap error:", err)
+		os.Exit(1)
+	}
+	if err = run(ctx); err != nil {
+		logger.New().Error(ctx, "server exited with error", "err", err)
+	}
+	// 服务退出前关闭数据库、Redis连接池, 最后把缓冲中的日志写入文件
+	if closeErr := shutdown(); closeErr != nil {
+		fmt.Fprintln(os.Stderr, "shutdown error:", closeErr)
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// run 启动HTTP服务和gRPC服务, 配置了内部接口的mTLS监听地址时再启动一个只提供内部接口的HTTP服务
+// 收到退出信号或者任一服务出错时, 等待处理中的请求完成后返回
+func run(ctx context.Context) error {
+	if config.App.Env != enum.ModeDev {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	engine := gin.New()
+	router.RegisterRoutes(engine)
+
+	tlsConfig, err := library.NewServerTLSConfig()
+	if err != nil {
+		return err
+	}
+	httpServers := []*http.Server{newHttpServer(config.Server.Addr, engine, tlsConfig)}
+	internalTLSConfig, err := library.NewInternalTLSConfig()
+	if err != nil {
+		return err
+	}
+	if internalTLSConfig != nil {
+		internalEngine := gin.New()
+		router.RegisterInternalRoutes(internalEngine)
+		httpServers = append(httpServers, newHttpServer(config.ServerTLS.InternalAddr, internalEngine, internalTLSConfig))
+	}
+	grpcServer, err := grpcserver.NewServer()
+	if err != nil {
+		return err
+	}
+	grpcListener, err := net.Listen("tcp", config.Grpc.Addr)
+	if err != nil {
+		return err
+	}
+
+	errCh := make(chan error, len(httpServers)+1)
+	for _, httpServer := range httpServers {
+		go func(httpServer *http.Server) {
+			logger.New().Info(ctx, "http server started", "addr", httpServer.Addr, "tls", httpServer.TLSConfig != nil)
+			var err error
+			if httpServer.TLSConfig != nil {
+				// 证书已经加载到 TLSConfig 中
+				err = httpServer.ListenAndServeTLS("", "")
+			} else {
+				err = httpServer.ListenAndServe()
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				errCh <- err
+			}
+		}(httpServer)
+	}
+	go func() {
+		logger.New().Info(ctx, "grpc server started", "addr", config.Grpc.Addr)
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			errCh <- err
+		}
+	}()
+
+	signalCtx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	// 网关转发认证的本地缓存收到吊销事件时立即失效
+	go appservice.SubscribeForwardAuthRevocations(signalCtx)
+	var serveErr error
+	select {
+	case <-signalCtx.Done():
+		logger.New().Info(ctx, "shutdown signal received")
+	case serveErr = <-errCh:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, config.Server.ShutdownTimeout)
+	defer cancel()
+	for _, httpServer := range httpServers {
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			// 超时后还没完成的请求直接断开
+			logger.New().Warn(ctx, "http server shutdown timeout", "addr", httpServer.Addr, "err", err)
+			httpServer.Close()
+		}
+	}
+	stopGrpcServer(shutdownCtx, grpcServer)
+	logger.New().Info(ctx, "server stopped")
+	return serveErr
+}
+
+func newHttpServer(addr string, handler http.Handler, tlsConfig *tls.Config) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		Handler:      handler,
+		ReadTimeout:  config.Server.ReadTimeout,
+		WriteTimeout: config.Server.WriteTimeout,
+		IdleTimeout:  config.Server.IdleTimeout,
+		TLSConfig:    tlsConfig,
+	}
+}
+
+// stopGrpcServer 等待处理中的RPC完成, 超时后强制关闭
+func stopGrpcServer(ctx context.Context, server *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logger.New().Warn(ctx, "grpc server shutdown timeout")
+		server.Stop()
+	}
+}
+
+// newServerContext 服务启动和退出过程中打日志使用的Context, 日志要求Context中有追踪信息
+func newServerContext() context.Context {
+	spanId := util.GenerateSpanID("127.0.0.1:0")
+	ctx := context.WithValue(context.Background(), "traceid", spanId)
+	ctx = context.WithValue(ctx, "spanid", spanId)
+	ctx = context.WithValue(ctx, "pspanid", "")
+	return ctx
+}
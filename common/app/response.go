@@ -13,6 +13,8 @@ type response struct {
 	RequestId  string      `json:"request_id"`
 	Data       interface{} `json:"data,omitempty"`
 	Pagination *pagination `json:"pagination,omitempty"`
+	// 参数校验不通过时返回每个字段的具体错误
+	Errors []*errcode.FieldError `json:"errors,omitempty"`
 }
 
 func NewResponse(c *gin.Context) *response {
@@ -46,6 +48,7 @@ func (r *response) SuccessOk() {
 func (r *response) Error(err *errcode.AppError) {
 	r.Code = err.Code()
 	r.Msg = err.Msg()
+	r.Errors = err.Fields()
 	if _, exists := r.ctx.Get("traceid"); exists {
 		val, _ := r.ctx.Get("traceid")
 		r.RequestId = val.(string)
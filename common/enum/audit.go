@@ -0,0 +1,15 @@
+package enum
+
+// 审计日志事件类型
+const (
+	AuditEventLoginSuccess   = "login_success"
+	AuditEventLoginStepUp    = "login_step_up"
+	AuditEventLoginDenied    = "login_denied"
+	AuditEventLoginFailed    = "login_failed"
+	AuditEventPasswordReset  = "password_reset"
+	AuditEventPasswordChange = "password_change"
+	AuditEventTokenExchange  = "token_exchange"
+	AuditEventApiKeyCreate   = "api_key_create"
+	AuditEventApiKeyRotate   = "api_key_rotate"
+	AuditEventApiKeyRevoke   = "api_key_revoke"
+)
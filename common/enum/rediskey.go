@@ -9,8 +9,45 @@ const (
 )
 
 const (
-	REDIS_KEY_ACCESS_TOKEN      = "GOMALL:USER:ACCESS_TOKEN_%s"
-	REDIS_KEY_REFRESH_TOKEN     = "GOMALL:USER:REFRESH_TOKEN_%s"
-	REDIS_KEY_USER_SESSION      = "GOMALL:USER:SESSION_%d"
-	REDISKEY_TOKEN_REFRESH_LOCK = "GOMALL:USER:TOKEN_REFRESH_LOCk_%s"
+	// 同一个用户的Token和Session使用 {userId} 作为hash tag, 集群模式下落在同一个slot, 发放Token的脚本才能同时操作它们
+	REDIS_KEY_ACCESS_TOKEN  = "GOMALL:USER:{%d}:ACCESS_TOKEN_%s"  // userId, Token
+	REDIS_KEY_REFRESH_TOKEN = "GOMALL:USER:{%d}:REFRESH_TOKEN_%s" // userId, Token
+	REDIS_KEY_USER_SESSION  = "GOMALL:USER:{%d}:SESSION"
+	// 加入hash tag之前的key, 开启 redis.legacy_session_keys 时读取不到新key会再读取旧key, 升级前登录的用户不用重新登录
+	REDIS_KEY_LEGACY_ACCESS_TOKEN  = "GOMALL:USER:ACCESS_TOKEN_%s"
+	REDIS_KEY_LEGACY_REFRESH_TOKEN = "GOMALL:USER:REFRESH_TOKEN_%s"
+	REDIS_KEY_LEGACY_USER_SESSION  = "GOMALL:USER:SESSION_%d"
+	REDISKEY_TOKEN_REFRESH_LOCK    = "GOMALL:USER:TOKEN_REFRESH_LOCk_%s"
+	REDIS_KEY_REFRESH_RESULT       = "GOMALL:USER:TOKEN_REFRESH_RESULT_%s" // 老的RefreshToken刷新得到的新Token
+	REDIS_KEY_EXCHANGED_TOKEN      = "GOMALL:USER:EXCHANGED_TOKEN_%s"
+	REDIS_KEY_API_KEY              = "GOMALL:USER:API_KEY_%s"
+	REDIS_KEY_REQUEST_NONCE        = "GOMALL:USER:REQUEST_NONCE_%s_%s"
+	// 用户会话被吊销的事件通知, 使用 Redis Pub/Sub, 下游服务收到后清理本地缓存的验证结果
+	REDIS_CHANNEL_SESSION_REVOKED = "GOMALL:USER:SESSION_REVOKED"
+)
+
+const (
+	REDIS_KEY_LOGIN_FAILURE_COUNT = "GOMALL:USER:{%d}:LOGIN_FAILURE_COUNT"
+	REDIS_KEY_RISK_BAD_IPS        = "GOMALL:USER:RISK_BAD_IPS"
+)
+
+const (
+	REDIS_KEY_VERIFY_CODE          = "GOMALL:USER:VERIFY_CODE_%s_%s"          // 场景, 手机号或邮箱
+	REDIS_KEY_VERIFY_CODE_COOLDOWN = "GOMALL:USER:VERIFY_CODE_COOLDOWN_%s_%s" // 场景, 手机号或邮箱
+)
+
+const (
+	REDIS_KEY_PASSWORD_RESET_TOKEN = "GOMALL:USER:{%d}:PASSWORD_RESET_TOKEN_%s" // userId, Token的哈希
+	REDIS_KEY_USER_PASSWORD_RESET  = "GOMALL:USER:{%d}:PASSWORD_RESET_USER"     // 用户当前有效的重置Token的哈希
+	REDIS_KEY_PASSWORD_RESET_LOCK  = "GOMALL:USER:{%d}:PASSWORD_RESET_LOCK"     // 使用重置Token设置密码时的锁
+)
+
+const (
+	REDIS_KEY_MAGIC_LINK = "GOMALL:USER:MAGIC_LINK_%s"
+	REDIS_KEY_QR_TICKET  = "GOMALL:USER:QR_TICKET_%s"
+)
+
+const (
+	REDIS_KEY_DEVICE_CODE      = "GOMALL:USER:DEVICE_CODE_%s"      // device_code的哈希
+	REDIS_KEY_DEVICE_USER_CODE = "GOMALL:USER:DEVICE_USER_CODE_%s" // user_code
 )
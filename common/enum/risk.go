@@ -0,0 +1,25 @@
+package enum
+
+// 登录结果, 对应登录历史中的result字段
+const (
+	LoginResultSuccess = 1
+	LoginResultFailed  = 2
+	LoginResultStepUp  = 3 // 命中风控, 需要二次验证
+	LoginResultDenied  = 4 // 命中风控, 拒绝登录
+)
+
+// 风控引擎对登录给出的处理动作
+const (
+	RiskActionAllow  = "allow"
+	RiskActionStepUp = "step_up"
+	RiskActionDeny   = "deny"
+)
+
+// 风控命中项
+const (
+	RiskReasonNewCountry       = "new_country"
+	RiskReasonNewDevice        = "new_device"
+	RiskReasonImpossibleTravel = "impossible_travel"
+	RiskReasonBadIp            = "bad_ip"
+	RiskReasonTooManyFailures  = "too_many_failures"
+)
@@ -7,6 +7,66 @@ const (
 	UserBlockStateBlocked = 1
 )
 
+// 用户登录的平台
+const (
+	PlatformApp = "app"
+	PlatformH5  = "h5"
+	PlatformPC  = "pc"
+	PlatformWx  = "wx"
+	PlatformTV  = "tv"  // 智能电视, 通过设备授权登录
+	PlatformCLI = "cli" // 命令行工具, 通过设备授权登录
+)
+
+// PC扫码登录二维码的状态, 二维码过期后缓存被删除, 查询时返回 expired
+const (
+	QrTicketStatusPending   = "pending"
+	QrTicketStatusScanned   = "scanned"
+	QrTicketStatusConfirmed = "confirmed"
+	QrTicketStatusExpired   = "expired"
+)
+
+// 设备授权请求的状态, 过期后缓存被删除
+const (
+	DeviceAuthStatusPending  = "pending"
+	DeviceAuthStatusApproved = "approved"
+	DeviceAuthStatusDenied   = "denied"
+)
+
+const (
+	UserVerifiedNo  = 0
+	UserVerifiedYes = 1
+)
+
+// OAuth 2.0 Token响应中的 token_type
+const TokenTypeBearer = "Bearer"
+
+// 查询Token详细信息时返回的Token类型
+const (
+	IntrospectTokenTypeAccess    = "access_token"
+	IntrospectTokenTypeExchanged = "exchanged_token"
+)
+
+// 请求认证的主体类型, 认证中间件写入 gin.Context 的 principalType
+const (
+	PrincipalTypeUser    = "user"    // 用户登录的会话
+	PrincipalTypeApiKey  = "api_key" // 机器客户端使用的API Key
+	PrincipalTypeService = "service" // 通过请求签名认证的内部服务
+)
+
+// API Key和受限会话可以申请的scope, 对应的接口通过 middleware.RequireScope 要求
+const (
+	ScopeUserRead = "user.read" // 查询当前用户的基本信息
+)
+
+// API Key的格式: gmk_<8位ID>_<32位密钥>, ID部分在列表中展示, 方便用户区分不同的Key
+const (
+	ApiKeyPrefix    = "gmk_"
+	ApiKeyIdLength  = 8
+	ApiKeySecretLen = 32
+)
+
 const AccessTokenDuration = 2 * time.Hour
 const RefreshTokenDuration = 24 * time.Hour * 10
 const OldRefreshTokenHoldingDuration = 6 * time.Hour // 刷新Token时老的RefreshToken保留的时间(用于发现refresh被窃取)
+const RefreshTokenGraceDuration = 30 * time.Second   // 刷新Token后, 老的RefreshToken再次刷新时返回同一组新Token的时间窗口(多标签页并发刷新)
+const RefreshTokenLockWait = 3 * time.Second         // 同一个RefreshToken正在被刷新时等待的最长时间
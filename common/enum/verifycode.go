@@ -0,0 +1,14 @@
+package enum
+
+// 验证码的使用场景, 不同场景的验证码互相不能通用
+// 邮件验证码使用 mailtemplate 中名为 <场景>_code 的模板
+const (
+	VerifyCodeSceneLogin       = "login"
+	VerifyCodeSceneVerifyEmail = "verify_email"
+	// 还没有设置过密码的用户第一次设置密码, 验证码发送到绑定的手机号, 没有手机号时发送到邮箱
+	VerifyCodeSceneSetPassword = "set_password"
+	// 找回密码不发验证码, 只使用这个场景做发送频率限制
+	VerifyCodeScenePasswordReset = "password_reset"
+	// 邮件登录链接同样只使用这个场景做发送频率限制
+	VerifyCodeSceneMagicLink = "magic_link"
+)
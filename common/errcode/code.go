@@ -15,6 +15,7 @@ var (
 	ErrForbidden       = newError(10000005, "未授权") // 访问一些未授权的资源时的错误
 	ErrTooManyRequests = newError(10000006, "请求过多")
 	ErrUserInvalid     = newError(10000007, "用户异常")
+	ErrBodyTooLarge    = newError(10000008, "请求体过大")
 )
 
 // 各个业务模块自定义的错误码, 从 10000100 开始, 可以按照不同的业务模块划分不同的号段
@@ -23,22 +24,74 @@ var (
 //	ErrOrderClosed  = NewError(10000100, "订单已关闭")
 //)
 
+// 用户认证模块的错误码, 10000100 ~ 10000199
+var (
+	ErrLoginNeedStepUp            = newError(10000100, "登录环境存在风险, 请完成身份验证")
+	ErrLoginDenied                = newError(10000101, "登录环境存在风险, 已拒绝本次登录")
+	ErrVerifyCodeSendTooFrequent  = newError(10000102, "验证码发送过于频繁, 请稍后再试")
+	ErrVerifyCodeInvalid          = newError(10000103, "验证码错误或已过期")
+	ErrVerifyCodeAttemptsExceeded = newError(10000104, "验证码错误次数过多, 请重新获取")
+	ErrUserNotRegistered          = newError(10000105, "用户未注册")
+	ErrEmailNotBound              = newError(10000106, "账号未绑定邮箱")
+	ErrEmailAlreadyVerified       = newError(10000107, "邮箱已完成验证")
+	ErrPasswordResetTokenInvalid  = newError(10000108, "重置密码链接无效或已过期")
+	ErrPasswordIncorrect          = newError(10000109, "密码错误")
+	ErrPasswordPolicy             = newError(10000110, "密码不符合安全要求")
+	ErrMagicLinkInvalid           = newError(10000111, "登录链接无效或已过期")
+	ErrQrTicketInvalid            = newError(10000112, "二维码无效或已过期")
+	ErrApiKeyLimitExceeded        = newError(10000113, "API Key数量已达上限")
+	ErrApiKeyNotFound             = newError(10000114, "API Key不存在")
+	ErrSignatureInvalid           = newError(10000115, "请求签名无效")
+	ErrSignatureExpired           = newError(10000116, "请求已过期")
+	ErrRequestReplayed            = newError(10000117, "重复的请求")
+	ErrCsrfTokenInvalid           = newError(10000118, "CSRF Token无效")
+	ErrSetPasswordNeedVerifyCode  = newError(10000119, "首次设置密码需要先验证手机号或邮箱")
+	ErrContactNotBound            = newError(10000120, "账号未绑定手机号或邮箱")
+)
+
+// OAuth 2.0 授权相关的错误码, 10000200 ~ 10000299
+// 在 /oauth 下的协议接口中会转换成 RFC 6749 定义的 error 响应
+var (
+	ErrOAuthInvalidRequest       = newError(10000200, "invalid_request")
+	ErrOAuthInvalidClient        = newError(10000201, "invalid_client")
+	ErrOAuthInvalidGrant         = newError(10000202, "invalid_grant")
+	ErrOAuthUnsupportedGrantType = newError(10000203, "unsupported_grant_type")
+	ErrOAuthInvalidScope         = newError(10000204, "invalid_scope")
+	ErrDeviceAuthPending         = newError(10000205, "authorization_pending")
+	ErrDeviceSlowDown            = newError(10000206, "slow_down")
+	ErrDeviceAccessDenied        = newError(10000207, "access_denied")
+	ErrDeviceCodeExpired         = newError(10000208, "expired_token")
+	ErrDeviceUserCodeInvalid     = newError(10000209, "设备验证码无效或已过期")
+	ErrOAuthInvalidTarget        = newError(10000210, "invalid_target")
+)
+
 func (e *AppError) HttpStatusCode() int {
 	switch e.Code() {
 	case Success.Code():
 		return http.StatusOK
 	case ErrServer.Code(), ErrPanic.Code():
 		return http.StatusInternalServerError
-	case ErrParams.Code():
+	case ErrParams.Code(), ErrVerifyCodeInvalid.Code(), ErrVerifyCodeAttemptsExceeded.Code(),
+		ErrEmailNotBound.Code(), ErrEmailAlreadyVerified.Code(), ErrPasswordResetTokenInvalid.Code(),
+		ErrPasswordIncorrect.Code(), ErrPasswordPolicy.Code(), ErrMagicLinkInvalid.Code(),
+		ErrQrTicketInvalid.Code(), ErrOAuthInvalidRequest.Code(), ErrOAuthInvalidGrant.Code(),
+		ErrOAuthUnsupportedGrantType.Code(), ErrOAuthInvalidScope.Code(), ErrDeviceAuthPending.Code(),
+		ErrDeviceSlowDown.Code(), ErrDeviceAccessDenied.Code(), ErrDeviceCodeExpired.Code(),
+		ErrDeviceUserCodeInvalid.Code(), ErrOAuthInvalidTarget.Code(), ErrApiKeyLimitExceeded.Code(),
+		ErrContactNotBound.Code():
 		return http.StatusBadRequest
-	case ErrNotFound.Code():
+	case ErrNotFound.Code(), ErrUserNotRegistered.Code(), ErrApiKeyNotFound.Code():
 		return http.StatusNotFound
-	case ErrTooManyRequests.Code():
+	case ErrTooManyRequests.Code(), ErrVerifyCodeSendTooFrequent.Code():
 		return http.StatusTooManyRequests
-	case ErrToken.Code():
+	case ErrToken.Code(), ErrOAuthInvalidClient.Code(), ErrSignatureInvalid.Code(), ErrSignatureExpired.Code(),
+		ErrRequestReplayed.Code():
 		return http.StatusUnauthorized
-	case ErrForbidden.Code():
+	case ErrForbidden.Code(), ErrLoginNeedStepUp.Code(), ErrLoginDenied.Code(), ErrCsrfTokenInvalid.Code(),
+		ErrSetPasswordNeedVerifyCode.Code():
 		return http.StatusForbidden
+	case ErrBodyTooLarge.Code():
+		return http.StatusRequestEntityTooLarge
 	default:
 		return http.StatusInternalServerError
 	}
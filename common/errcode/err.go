@@ -8,10 +8,18 @@ import (
 )
 
 type AppError struct {
-	code     int    `json:"code"`
-	msg      string `json:"msg"`
-	cause    error  `json:"cause"`
-	occurred string `json:"occurred"` // 保存由底层错误导致AppErr发生时的位置
+	code     int           `json:"code"`
+	msg      string        `json:"msg"`
+	cause    error         `json:"cause"`
+	occurred string        `json:"occurred"` // 保存由底层错误导致AppErr发生时的位置
+	fields   []*FieldError // 参数校验不通过时每个字段的具体错误
+}
+
+// FieldError 参数中某个字段校验不通过的具体原因
+type FieldError struct {
+	Field string `json:"field"` // 字段名
+	Rule  string `json:"rule"`  // 未通过的规则
+	Msg   string `json:"msg"`   // 错误提示
 }
 
 func (e *AppError) Error() string {
@@ -59,6 +67,18 @@ func (e *AppError) WithCause(err error) *AppError {
 	return e
 }
 
+// WithFields 附加字段校验错误, 与 WithCause 不同这里返回的是预定义错误的副本
+// 因为字段错误会直接响应给客户端, 不能让并发的请求互相覆盖
+func (e *AppError) WithFields(fields ...*FieldError) *AppError {
+	newErr := *e
+	newErr.fields = fields
+	return &newErr
+}
+
+func (e *AppError) Fields() []*FieldError {
+	return e.fields
+}
+
 func newError(code int, msg string) *AppError {
 	if code > -1 {
 		if _, duplicated := codes[code]; duplicated {
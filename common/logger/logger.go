@@ -32,8 +32,11 @@ func (l *logger) Error(ctx context.Context, msg string, kv ...interface{}) {
 func traceInfo(ctx context.Context) []interface{} {
 	// 日志行信息中增加追踪参数
 	list := make([]interface{}, 0, 6)
-	list = append(list, "traceid", ctx.Value("traceid").(string), "spanid", ctx.Value("spanid").(string),
-		"pspanid", ctx.Value("pspanid").(string))
+	// 后台任务和测试中的Context可能没有追踪信息, 这时记为空
+	traceId, _ := ctx.Value("traceid").(string)
+	spanId, _ := ctx.Value("spanid").(string)
+	pSpanId, _ := ctx.Value("pspanid").(string)
+	list = append(list, "traceid", traceId, "spanid", spanId, "pspanid", pSpanId)
 	return list
 }
 
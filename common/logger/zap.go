@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"errors"
 	"github.com/ljinf/user_auth/common/enum"
 	"github.com/ljinf/user_auth/config"
 	"go.uber.org/zap"
@@ -9,9 +10,14 @@ import (
 	"os"
 )
 
-var _logger *zap.Logger
+// 未初始化时丢弃所有日志, 单元测试中不需要配置文件
+var _logger = zap.NewNop()
 
-func init() {
+// Init 按应用配置创建日志, 需要在 config.Load 之后调用
+func Init() error {
+	if config.App == nil {
+		return errors.New("logger: app config not loaded")
+	}
 	encoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	encoder := zapcore.NewJSONEncoder(encoderConfig)
@@ -31,6 +37,17 @@ func init() {
 	}
 	core := zapcore.NewTee(cores...)
 	_logger = zap.New(core)
+	return nil
+}
+
+// SetLogger 替换底层的zap日志, 测试中可以传入 zaptest.NewLogger 把日志输出到测试结果里
+func SetLogger(l *zap.Logger) {
+	_logger = l
+}
+
+// Sync 把缓冲中的日志写入文件, 服务退出前调用
+func Sync() error {
+	return _logger.Sync()
 }
 
 func getFileLogWriter() zapcore.WriteSyncer {
@@ -3,33 +3,108 @@ package middleware
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/ljinf/user_auth/common/app"
+	"github.com/ljinf/user_auth/common/enum"
 	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/config"
 	"github.com/ljinf/user_auth/logic/domainservice"
+	"strings"
 )
 
 // 用户认证相关的中间件
 
+// AuthUser 认证用户身份, 按配置的读取链从请求中读取Token, 再按Token的格式交给对应的认证方式处理
+// 支持用户登录的AccessToken和机器客户端使用的API Key, 认证方式与网关转发认证相同
+// 认证通过后在Context中写入 userId 和 principalType, Token认证还会写入 sessionId, API Key认证会写入 apiKeyId
+// API Key和设备授权等受限会话还会写入允许的 scope
 func AuthUser() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := c.Request.Header.Get("go-mall-token")
-		if len(token) != 40 { // 我们生成的token长度为40
+		token, source := ExtractToken(c)
+		if token == "" {
 			app.NewResponse(c).Error(errcode.ErrToken)
 			c.Abort()
 			return
 		}
-		tokenVerify, err := domainservice.NewUserDomainSvc(c).VerifyAccessToken(token)
+		if source == TokenSourceCookie && !CheckCsrf(c, c.Request.Method) {
+			// Cookie会被浏览器自动携带, 修改状态的请求需要通过CSRF校验
+			app.NewResponse(c).Error(errcode.ErrCsrfTokenInvalid)
+			c.Abort()
+			return
+		}
+		principal, err := domainservice.NewAuthDomainSvc(c).Authenticate(token)
 		if err != nil { // 验证Token时服务出错
 			app.NewResponse(c).Error(errcode.ErrServer)
 			c.Abort()
 			return
 		}
-		if !tokenVerify.Approved { // Token未通过验证
+		if principal == nil { // Token未通过验证
 			app.NewResponse(c).Error(errcode.ErrToken)
 			c.Abort()
 			return
 		}
-		c.Set("userId", tokenVerify.UserId)
-		c.Set("sessionId", tokenVerify.SessionId)
+		c.Set("userId", principal.UserId)
+		c.Set("principalType", principal.Type)
+		switch principal.Type {
+		case enum.PrincipalTypeUser:
+			c.Set("sessionId", principal.SessionId)
+		case enum.PrincipalTypeApiKey:
+			c.Set("apiKeyId", principal.ApiKeyId)
+		}
+		if principal.Scope != "" {
+			c.Set("scope", principal.Scope)
+		}
+		c.Next()
+	}
+}
+
+// RequireSession 要求请求来自用户完整登录的会话, 修改密码、管理API Key等敏感操作不允许使用API Key和受限会话, 需要放在AuthUser之后
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("principalType") != enum.PrincipalTypeUser || scopeRestricted(c) {
+			app.NewResponse(c).Error(errcode.ErrForbidden)
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
+
+// RequireScope 使用API Key或者受限会话访问时要求拥有指定的scope, 用户完整登录的会话不受限制, 需要放在AuthUser之后
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if scopeRestricted(c) {
+			granted := false
+			for _, s := range strings.Fields(c.GetString("scope")) {
+				if s == scope {
+					granted = true
+					break
+				}
+			}
+			if !granted {
+				app.NewResponse(c).Error(errcode.ErrForbidden)
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// scopeRestricted 请求的身份是否只能使用 scope 中的权限, API Key即使没有任何scope也是受限的
+func scopeRestricted(c *gin.Context) bool {
+	return c.GetString("principalType") == enum.PrincipalTypeApiKey || c.GetString("scope") != ""
+}
+
+// AdminUser 要求当前登录的用户是管理员, 需要放在AuthUser之后
+func AdminUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.GetInt64("userId")
+		for _, adminId := range config.ApiKey.AdminUserIds {
+			if adminId == userId {
+				c.Next()
+				return
+			}
+		}
+		app.NewResponse(c).Error(errcode.ErrForbidden)
+		c.Abort()
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"github.com/ljinf/user_auth/common/app"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/config"
+	"net/http"
+)
+
+// CsrfProtect 请求携带了会话Cookie时, 对修改状态的请求做双重提交Cookie校验
+// 登录时下发的CSRF Cookie可以被页面JS读取, 页面需要把它放到请求头中一起提交, 跨站请求无法读取Cookie也就无法伪造请求头
+func CsrfProtect() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasSessionCookie(c) || CheckCsrf(c, c.Request.Method) {
+			c.Next()
+			return
+		}
+		app.NewResponse(c).Error(errcode.ErrCsrfTokenInvalid)
+		c.Abort()
+	}
+}
+
+func hasSessionCookie(c *gin.Context) bool {
+	if config.SessionCookie == nil || !config.SessionCookie.Enabled {
+		return false
+	}
+	for _, name := range []string{config.SessionCookie.AccessCookie, config.SessionCookie.RefreshCookie} {
+		if value, err := c.Cookie(name); err == nil && value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckCsrf 安全的请求方法不校验, 其他请求要求请求头中的CSRF Token与Cookie中的一致
+// method 为需要保护的请求的方法, 网关转发认证时是原始请求的方法
+func CheckCsrf(c *gin.Context, method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	cookieToken, err := c.Cookie(config.SessionCookie.CsrfCookie)
+	if err != nil || cookieToken == "" {
+		return false
+	}
+	headerToken := c.Request.Header.Get(config.SessionCookie.CsrfHeader)
+	return subtle.ConstantTimeCompare([]byte(cookieToken), []byte(headerToken)) == 1
+}
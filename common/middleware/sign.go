@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/ljinf/user_auth/common/app"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/library"
+	"github.com/ljinf/user_auth/logic/domainservice"
+	"io"
+	"net/http"
+)
+
+// AuthService 认证内部服务的身份
+// 调用方提供了配置中的客户端证书(mTLS)时直接通过, 否则校验请求签名, 调用方可以使用 httptool.WithSignature 对请求签名
+// 认证通过后在Context中写入 principalType 和调用方的服务名 service
+func AuthService() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if service := library.CertPrincipal(c.Request.TLS); service != "" {
+			c.Set("service", service)
+			c.Set("principalType", enum.PrincipalTypeService)
+			c.Next()
+			return
+		}
+		// 签名需要读取完整的请求体, 限制大小防止读入过大的请求体耗尽内存
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.RequestSign.MaxBodyBytes)
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				app.NewResponse(c).Error(errcode.ErrBodyTooLarge)
+			} else {
+				app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+			}
+			c.Abort()
+			return
+		}
+		// 读取后写回请求体, 后续的Handler还需要读取
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		signKey, err := domainservice.NewRequestSignDomainSvc(c).Verify(&domainservice.SignedRequest{
+			KeyId:      c.Request.Header.Get(util.SignHeaderKeyId),
+			Timestamp:  c.Request.Header.Get(util.SignHeaderTimestamp),
+			Nonce:      c.Request.Header.Get(util.SignHeaderNonce),
+			Signature:  c.Request.Header.Get(util.SignHeaderSignature),
+			Method:     c.Request.Method,
+			RequestUri: c.Request.URL.RequestURI(),
+			Body:       body,
+		})
+		if err != nil {
+			if appErr, ok := err.(*errcode.AppError); ok && appErr.Code() != -1 {
+				app.NewResponse(c).Error(appErr)
+			} else {
+				app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+			}
+			c.Abort()
+			return
+		}
+		c.Set("service", signKey.Service)
+		c.Set("principalType", enum.PrincipalTypeService)
+		c.Next()
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/config"
+	"strings"
+)
+
+// 认证中间件从请求中读取Token的方式, 按配置的顺序组成读取链, 名称在加载配置时校验
+
+// tokenExtractor 从请求中读取Token, 读不到时返回空字符串
+type tokenExtractor func(c *gin.Context) string
+
+var tokenExtractors = map[string]tokenExtractor{
+	"bearer":         bearerExtractor,
+	"header":         headerExtractor("go-mall-token"),
+	"api_key_header": headerExtractor("go-mall-api-key"),
+	"cookie":         cookieExtractor,
+	"query":          queryExtractor,
+}
+
+// TokenSourceCookie 记录Token是否来自Cookie, 来自Cookie的Token需要通过CSRF校验
+const TokenSourceCookie = "cookie"
+
+// ExtractToken 按配置的顺序读取Token, 返回Token和读取方式
+func ExtractToken(c *gin.Context) (token, source string) {
+	for _, name := range config.Auth.TokenExtractors {
+		if token = tokenExtractors[name](c); token != "" {
+			return token, name
+		}
+	}
+	return "", ""
+}
+
+func bearerExtractor(c *gin.Context) string {
+	authorization := c.Request.Header.Get("Authorization")
+	if len(authorization) > len(enum.TokenTypeBearer)+1 &&
+		strings.EqualFold(authorization[:len(enum.TokenTypeBearer)], enum.TokenTypeBearer) &&
+		authorization[len(enum.TokenTypeBearer)] == ' ' {
+		return strings.TrimSpace(authorization[len(enum.TokenTypeBearer)+1:])
+	}
+	return ""
+}
+
+func headerExtractor(name string) tokenExtractor {
+	return func(c *gin.Context) string {
+		return c.Request.Header.Get(name)
+	}
+}
+
+func cookieExtractor(c *gin.Context) string {
+	if config.SessionCookie == nil || !config.SessionCookie.Enabled {
+		return ""
+	}
+	token, _ := c.Cookie(config.SessionCookie.AccessCookie)
+	return token
+}
+
+// queryExtractor 浏览器发起WebSocket握手时不能设置请求头, 只在握手请求中从查询参数读取Token
+func queryExtractor(c *gin.Context) string {
+	if !strings.EqualFold(c.Request.Header.Get("Upgrade"), "websocket") {
+		return ""
+	}
+	return c.Query(config.Auth.QueryParam)
+}
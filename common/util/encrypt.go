@@ -43,9 +43,12 @@ func AesDecrypt(crypted, key []byte) ([]byte, error) {
 
 func PKCS5UnPadding(origData []byte) []byte {
 	length := len(origData)
+	if length == 0 {
+		return origData
+	}
 	// 去掉最后一个字节 unPadding 次
 	unPadding := int(origData[length-1])
-	if unPadding < 1 || unPadding > 32 {
+	if unPadding < 1 || unPadding > 32 || unPadding > length {
 		unPadding = 0
 	}
 	return origData[:(length - unPadding)]
@@ -0,0 +1,15 @@
+package util
+
+import "math"
+
+const earthRadiusKm = 6371.0
+
+// GeoDistance 使用 Haversine 公式计算两个经纬度坐标之间的球面距离, 单位: km
+func GeoDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
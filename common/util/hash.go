@@ -0,0 +1,21 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Sha256Hex 计算字符串的 SHA-256 哈希, 返回十六进制字符串
+// 重置密码的Token等凭证只在缓存中存储哈希值
+func Sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// HmacSha256Hex 使用key计算数据的 HMAC-SHA256 签名, 返回十六进制字符串
+func HmacSha256Hex(key, data string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
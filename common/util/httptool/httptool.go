@@ -3,6 +3,8 @@ package httptool
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"github.com/ljinf/user_auth/common/errcode"
@@ -10,6 +12,9 @@ import (
 	"github.com/ljinf/user_auth/common/util"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -40,6 +45,11 @@ func Request(method string, url string, options ...Option) (httpStatusCode int,
 	traceId, spanId, _ := util.GetTraceInfoFromCtx(reqOpts.ctx)
 	reqOpts.headers["traceid"] = traceId
 	reqOpts.headers["spanid"] = spanId
+	if reqOpts.signKeyId != "" { // 内部服务间调用时对请求签名
+		if err = signRequest(req, reqOpts); err != nil {
+			return
+		}
+	}
 	if len(reqOpts.headers) != 0 { // 设置请求头
 		for key, value := range reqOpts.headers {
 			req.Header.Add(key, value)
@@ -47,6 +57,11 @@ func Request(method string, url string, options ...Option) (httpStatusCode int,
 	}
 	// 发起请求
 	client := &http.Client{Timeout: reqOpts.timeout}
+	if reqOpts.certFile != "" || reqOpts.caFile != "" {
+		if client.Transport, err = tlsTransport(reqOpts); err != nil {
+			return
+		}
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return
@@ -95,6 +110,13 @@ type requestOption struct {
 	timeout time.Duration
 	data    []byte
 	headers map[string]string
+	// 请求签名使用的密钥, 设置后自动对请求签名
+	signKeyId  string
+	signSecret string
+	// 客户端证书和自定义CA, 设置后使用对应的TLS配置发起请求
+	certFile string
+	keyFile  string
+	caFile   string
 }
 
 type Option interface {
@@ -145,3 +167,73 @@ func WithData(data []byte) Option {
 		return
 	})
 }
+
+// WithSignature 使用内部服务分配的密钥对请求签名, 签名在请求发起前根据最终的请求方法、路径和请求体计算
+func WithSignature(keyId, secret string) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		opts.signKeyId, opts.signSecret = keyId, secret
+		return
+	})
+}
+
+func signRequest(req *http.Request, opts *requestOption) error {
+	nonce, err := util.SecureRandString(32, util.Alphanumeric)
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	signature := util.SignRequest(opts.signSecret, req.Method, req.URL.RequestURI(), opts.data, timestamp, nonce)
+	opts.headers[util.SignHeaderKeyId] = opts.signKeyId
+	opts.headers[util.SignHeaderTimestamp] = strconv.FormatInt(timestamp, 10)
+	opts.headers[util.SignHeaderNonce] = nonce
+	opts.headers[util.SignHeaderSignature] = signature
+	return nil
+}
+
+// WithClientCert 使用客户端证书发起请求, 用于对端要求 mTLS 认证的场景
+func WithClientCert(certFile, keyFile string) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		opts.certFile, opts.keyFile = certFile, keyFile
+		return
+	})
+}
+
+// WithRootCAs 使用自定义的CA证书校验对端的证书, 用于对端使用内部CA签发证书的场景
+func WithRootCAs(caFile string) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		opts.caFile = caFile
+		return
+	})
+}
+
+// tlsTransports 按证书文件缓存 Transport, 复用连接池, 避免每次请求都重新加载证书和建立连接
+var tlsTransports sync.Map
+
+func tlsTransport(opts *requestOption) (*http.Transport, error) {
+	cacheKey := opts.certFile + "|" + opts.keyFile + "|" + opts.caFile
+	if transport, ok := tlsTransports.Load(cacheKey); ok {
+		return transport.(*http.Transport), nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.certFile, opts.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if opts.caFile != "" {
+		pem, err := os.ReadFile(opts.caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate found in " + opts.caFile)
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	actual, _ := tlsTransports.LoadOrStore(cacheKey, transport)
+	return actual.(*http.Transport), nil
+}
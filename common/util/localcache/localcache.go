@@ -0,0 +1,71 @@
+package localcache
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache 进程内的TTL缓存, 用于缓存短时间内可以复用的结果, 减少对Redis的访问
+// 缓存满时先清理过期的条目, 仍然满时随机淘汰一个条目
+type Cache[K comparable, V any] struct {
+	mu         sync.Mutex
+	items      map[K]item[V]
+	maxEntries int
+}
+
+type item[V any] struct {
+	value    V
+	expireAt time.Time
+}
+
+func New[K comparable, V any](maxEntries int) *Cache[K, V] {
+	return &Cache[K, V]{
+		items:      make(map[K]item[V]),
+		maxEntries: maxEntries,
+	}
+}
+
+func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	it, ok := c.items[key]
+	if !ok {
+		return value, false
+	}
+	if time.Now().After(it.expireAt) {
+		delete(c.items, key)
+		return value, false
+	}
+	return it.value, true
+}
+
+func (c *Cache[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.items[key]; !exists && c.maxEntries > 0 && len(c.items) >= c.maxEntries {
+		c.evict()
+	}
+	c.items[key] = item[V]{value: value, expireAt: time.Now().Add(ttl)}
+}
+
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, key)
+}
+
+func (c *Cache[K, V]) evict() {
+	now := time.Now()
+	for key, it := range c.items {
+		if now.After(it.expireAt) {
+			delete(c.items, key)
+		}
+	}
+	if len(c.items) < c.maxEntries {
+		return
+	}
+	for key := range c.items {
+		delete(c.items, key)
+		return
+	}
+}
@@ -0,0 +1,17 @@
+package util
+
+import "golang.org/x/crypto/bcrypt"
+
+// HashPassword 使用 bcrypt 生成密码哈希, 哈希中已包含随机盐
+func HashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// CheckPassword 校验密码是否与哈希匹配
+func CheckPassword(hashedPassword, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)) == nil
+}
@@ -1,6 +1,8 @@
 package util
 
 import (
+	crand "crypto/rand"
+	"math/big"
 	"math/rand"
 	"time"
 )
@@ -56,3 +58,22 @@ func RandNumStr(len uint8) string {
 	r.SetCharset(Numeric)
 	return r.String(len)
 }
+
+// SecureRandNumStr 使用 crypto/rand 生成数字字符串, 验证码等安全场景请使用这个方法而不是 RandNumStr
+func SecureRandNumStr(length uint8) (string, error) {
+	return SecureRandString(length, Numeric)
+}
+
+// SecureRandString 使用 crypto/rand 从charset中随机挑选字符生成字符串
+func SecureRandString(length uint8, charset Charset) (string, error) {
+	b := make([]byte, length)
+	max := big.NewInt(int64(len(charset)))
+	for i := range b {
+		n, err := crand.Int(crand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = charset[n.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,27 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+)
+
+// 内部服务间调用的请求签名使用的请求头
+const (
+	SignHeaderKeyId     = "go-mall-key-id"
+	SignHeaderTimestamp = "go-mall-timestamp"
+	SignHeaderNonce     = "go-mall-nonce"
+	SignHeaderSignature = "go-mall-signature"
+)
+
+// SignRequest 计算请求的签名
+// 待签名的内容按行拼接: 请求方法、请求路径(包含查询参数)、请求体的SHA256、Unix时间戳(秒)、随机数
+func SignRequest(secret, method, requestUri string, body []byte, timestamp int64, nonce string) string {
+	canonical := strings.Join([]string{
+		strings.ToUpper(method),
+		requestUri,
+		Sha256Hex(string(body)),
+		strconv.FormatInt(timestamp, 10),
+		nonce,
+	}, "\n")
+	return HmacSha256Hex(secret, canonical)
+}
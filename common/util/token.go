@@ -1,6 +1,7 @@
 package util
 
 import (
+	"bytes"
 	"crypto/md5"
 	"encoding/binary"
 	"encoding/hex"
@@ -54,12 +55,19 @@ func ParseUserIdFromToken(accessToken string) (userId int64, err error) {
 		// Token 格式不对
 		return
 	}
-	encodeStr := accessToken[md5Len*2:]
-	data, err := hex.DecodeString(encodeStr)
+	data, err := hex.DecodeString(accessToken)
 	if err != nil {
 		return
 	}
-	decodeByte, _ := AesDecrypt(data, []byte(aesKEY)) //忽略错误
+	decodeByte, err := AesDecrypt(data[md5Len:], []byte(aesKEY))
+	if err != nil || len(decodeByte) != 12 {
+		return
+	}
+	// 校验MD5部分, 伪造的Token解不出userId
+	md5Byte := md5.Sum(decodeByte)
+	if !bytes.Equal(md5Byte[:md5Len], data[:md5Len]) {
+		return
+	}
 	uid := binary.BigEndian.Uint64(decodeByte)
 	if uid == 0 {
 		return
@@ -67,3 +75,31 @@ func ParseUserIdFromToken(accessToken string) (userId int64, err error) {
 	userId = int64(uid)
 	return
 }
+
+// GenUserBoundToken 生成一次性使用的Token, 前40个字符与AccessToken格式相同, 可以解析出userId用作Redis key的hash tag,
+// 后面拼接 randLen 个安全随机字符保证Token不可猜测
+func GenUserBoundToken(uid int64, randLen uint8) (string, error) {
+	prefix, err := genAccessToken(uid)
+	if err != nil {
+		return "", err
+	}
+	random, err := SecureRandString(randLen, Alphanumeric)
+	if err != nil {
+		return "", err
+	}
+	return prefix + random, nil
+}
+
+// ParseUserIdFromBoundToken 从 GenUserBoundToken 生成的Token中解析出userId, 格式不对时返回0
+func ParseUserIdFromBoundToken(token string) int64 {
+	if len(token) <= 2*(md5Len+aesLen) {
+		return 0
+	}
+	userId, _ := ParseUserIdFromToken(token[:2*(md5Len+aesLen)])
+	return userId
+}
+
+// GenDelegatedToken 生成Token交换场景下代表用户调用其他服务的Token, 格式与AccessToken相同
+func GenDelegatedToken(uid int64) (string, error) {
+	return genAccessToken(uid)
+}
@@ -0,0 +1,72 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseUserIdFromToken(t *testing.T) {
+	accessToken, _, err := GenUserAuthToken(10086)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zeroToken, err := genAccessToken(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 改掉MD5部分, AES部分仍然可以解密
+	forged := "00000000" + accessToken[8:]
+	if forged == accessToken {
+		forged = "11111111" + accessToken[8:]
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		userId int64
+	}{
+		{name: "valid", token: accessToken, userId: 10086},
+		{name: "empty", token: "", userId: 0},
+		{name: "too short", token: accessToken[:39], userId: 0},
+		{name: "too long", token: accessToken + "0", userId: 0},
+		{name: "not hex", token: strings.Repeat("z", 40), userId: 0},
+		{name: "forged md5", token: forged, userId: 0},
+		{name: "zero user", token: zeroToken, userId: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userId, _ := ParseUserIdFromToken(tt.token)
+			if userId != tt.userId {
+				t.Errorf("ParseUserIdFromToken() = %d, want %d", userId, tt.userId)
+			}
+		})
+	}
+}
+
+func TestParseUserIdFromBoundToken(t *testing.T) {
+	token, err := GenUserBoundToken(10086, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken, _, err := GenUserAuthToken(10086)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		userId int64
+	}{
+		{name: "valid", token: token, userId: 10086},
+		{name: "without random part", token: accessToken, userId: 0},
+		{name: "bad prefix", token: strings.Repeat("0", 40) + token[40:], userId: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if userId := ParseUserIdFromBoundToken(tt.token); userId != tt.userId {
+				t.Errorf("ParseUserIdFromBoundToken() = %d, want %d", userId, tt.userId)
+			}
+		})
+	}
+}
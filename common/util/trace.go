@@ -41,3 +41,13 @@ func GetTraceInfoFromCtx(ctx context.Context) (traceId, spanId, pSpanId string)
 
 	return
 }
+
+// DetachTraceContext 返回只带有ctx中追踪信息的新Context, 用于请求返回后还要继续执行的后台任务
+// 新Context不会随着请求结束被取消
+func DetachTraceContext(ctx context.Context) context.Context {
+	traceId, spanId, pSpanId := GetTraceInfoFromCtx(ctx)
+	detached := context.WithValue(context.Background(), "traceid", traceId)
+	detached = context.WithValue(detached, "spanid", spanId)
+	detached = context.WithValue(detached, "pspanid", pSpanId)
+	return detached
+}
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"github.com/spf13/viper"
 	"os"
+	"strings"
 )
 
 // **嵌入文件只能在写embed指令的Go文件的同级目录或者子目录中
@@ -10,27 +12,123 @@ import (
 /*//go:embed *.yaml
 var configs embed.FS*/
 
-func init() {
+// DefaultFile 根据环境变量 ENV 决定要读取的应用启动配置, 未设置时使用dev环境的配置
+func DefaultFile() string {
 	env := os.Getenv("ENV")
+	if env == "" {
+		env = "dev"
+	}
+	return "./config/application." + env + ".yaml"
+}
+
+// Load 读取配置文件并解析到各项配置中, file 为空时读取 DefaultFile
+func Load(file string) error {
+	if file == "" {
+		file = DefaultFile()
+	}
 	vp := viper.New()
+	vp.SetConfigFile(file)
+	if err := vp.ReadInConfig(); err != nil {
+		return err
+	}
 
-	// 根据环境变量 ENV 决定要读取的应用启动配置
-	//configFileStream, err := configs.ReadFile("application." + env + ".yaml")
-	//if err != nil {
-	//	panic(err)
-	//}
+	sections := []struct {
+		key    string
+		rawVal interface{}
+	}{
+		{"app", &App},
+		{"server", &Server},
+		{"database", &Database},
+		{"redis", &Redis},
+		{"risk", &Risk},
+		{"verify_code", &VerifyCode},
+		{"sms", &Sms},
+		{"mail", &Mail},
+		{"password_reset", &PasswordReset},
+		{"password_policy", &PasswordPolicy},
+		{"magic_link", &MagicLink},
+		{"qr_login", &QrLogin},
+		{"device_auth", &DeviceAuth},
+		{"token_exchange", &TokenExchange},
+		{"api_key", &ApiKey},
+		{"request_sign", &RequestSign},
+		{"server_tls", &ServerTLS},
+		{"session_cookie", &SessionCookie},
+		{"auth", &Auth},
+		{"grpc", &Grpc},
+	}
+	for _, section := range sections {
+		if err := vp.UnmarshalKey(section.key, section.rawVal); err != nil {
+			return fmt.Errorf("config %s: %w", section.key, err)
+		}
+	}
 
-	if env == "" {
-		env = "dev"
+	// 必须配置的部分, 缺少时启动失败并列出全部缺少的部分
+	var missing []string
+	for _, required := range []struct {
+		key     string
+		present bool
+	}{
+		{"app", App != nil},
+		{"server", Server != nil},
+		{"database", Database != nil},
+		{"redis", Redis != nil},
+		{"sms", Sms != nil},
+		{"mail", Mail != nil},
+		{"password_reset", PasswordReset != nil},
+		{"magic_link", MagicLink != nil},
+	} {
+		if !required.present {
+			missing = append(missing, required.key)
+		}
 	}
-	vp.SetConfigFile("./config/application." + env + ".yaml")
-	err := vp.ReadInConfig()
-	if err != nil {
-		panic(err)
+	if len(missing) > 0 {
+		return fmt.Errorf("config missing required sections: %s", strings.Join(missing, ", "))
 	}
 
-	vp.UnmarshalKey("app", &App)
-	vp.UnmarshalKey("database", &Database)
+	// 可选的部分没有配置时使用默认值
+	setDefaults(&Server)
+	setDefaults(&Risk)
+	setDefaults(&VerifyCode)
+	setDefaults(&PasswordReset)
+	setDefaults(&PasswordPolicy)
+	setDefaults(&MagicLink)
+	setDefaults(&QrLogin)
+	setDefaults(&DeviceAuth)
+	setDefaults(&TokenExchange)
+	setDefaults(&ApiKey)
+	setDefaults(&RequestSign)
+	setDefaults(&ServerTLS)
+	setDefaults(&SessionCookie)
+	setDefaults(&Auth)
+	setDefaults(&Grpc)
+
+	validators := []struct {
+		key       string
+		validator interface{ validate() error }
+	}{
+		{"database", Database},
+		{"risk", Risk},
+		{"password_reset", PasswordReset},
+		{"password_policy", PasswordPolicy},
+		{"magic_link", MagicLink},
+		{"auth", Auth},
+	}
+	for _, section := range validators {
+		if err := section.validator.validate(); err != nil {
+			return fmt.Errorf("config %s: %w", section.key, err)
+		}
+	}
+	return nil
+}
 
-	vp.UnmarshalKey("redis", &Redis)
+// setDefaults 没有配置的部分创建零值配置, 再补全没有配置的参数
+func setDefaults[T any, PT interface {
+	*T
+	setDefaults()
+}](section *PT) {
+	if *section == nil {
+		*section = new(T)
+	}
+	(*section).setDefaults()
 }
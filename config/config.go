@@ -1,12 +1,32 @@
 package config
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // 项目通过这里的变量读取应用配置中的对应项
 var (
-	App      *appConfig
-	Database *databaseConfig
-	Redis    *redisConfig
+	App            *appConfig
+	Server         *serverConfig
+	Database       *databaseConfig
+	Redis          *redisConfig
+	Risk           *riskConfig
+	VerifyCode     *verifyCodeConfig
+	Sms            *smsConfig
+	Mail           *mailConfig
+	PasswordReset  *passwordResetConfig
+	PasswordPolicy *passwordPolicyConfig
+	MagicLink      *magicLinkConfig
+	QrLogin        *qrLoginConfig
+	DeviceAuth     *deviceAuthConfig
+	TokenExchange  *tokenExchangeConfig
+	ApiKey         *apiKeyConfig
+	RequestSign    *requestSignConfig
+	ServerTLS      *serverTLSConfig
+	SessionCookie  *sessionCookieConfig
+	Auth           *authConfig
+	Grpc           *grpcConfig
 )
 
 type appConfig struct {
@@ -23,12 +43,50 @@ type appConfig struct {
 	} `mapstructure:"pagination"`
 }
 
+type serverConfig struct {
+	Addr            string        `mapstructure:"addr"`             // HTTP服务监听地址
+	ReadTimeout     time.Duration `mapstructure:"read_timeout"`     // 读取整个请求(包括Body)的超时时间
+	WriteTimeout    time.Duration `mapstructure:"write_timeout"`    // 写响应的超时时间, 扫码登录的SSE推送会受它限制
+	IdleTimeout     time.Duration `mapstructure:"idle_timeout"`     // Keep-Alive连接空闲的超时时间
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"` // 收到退出信号后等待处理中请求完成的最长时间
+}
+
+// setDefaults 补全没有配置的HTTP服务参数
+func (sc *serverConfig) setDefaults() {
+	if sc.Addr == "" {
+		sc.Addr = ":8080"
+	}
+	if sc.ReadTimeout <= 0 {
+		sc.ReadTimeout = 10 * time.Second
+	}
+	if sc.WriteTimeout <= 0 {
+		sc.WriteTimeout = 3 * time.Minute
+	}
+	if sc.IdleTimeout <= 0 {
+		sc.IdleTimeout = time.Minute
+	}
+	if sc.ShutdownTimeout <= 0 {
+		sc.ShutdownTimeout = 30 * time.Second
+	}
+}
+
 type databaseConfig struct {
 	Type   string          `mapstructure:"type"`
 	Master DbConnectOption `mapstructure:"master"`
 	Slave  DbConnectOption `mapstructure:"slave"`
 }
 
+// validate 主库和从库都要配置连接地址, 没有读写分离时两者配置成同一个地址
+func (dc *databaseConfig) validate() error {
+	if dc.Master.DSN == "" {
+		return fmt.Errorf("master dsn is required")
+	}
+	if dc.Slave.DSN == "" {
+		return fmt.Errorf("slave dsn is required")
+	}
+	return nil
+}
+
 type DbConnectOption struct {
 	DSN         string        `mapstructure:"dsn"`
 	MaxOpenConn int           `mapstructure:"maxopen"`
@@ -38,8 +96,428 @@ type DbConnectOption struct {
 
 // Redis 配置
 type redisConfig struct {
-	Addr     string `mapstructure:"addr"`
-	Password string `mapstructure:"password"`
-	PoolSize int    `mapstructure:"pool_size"`
-	DB       int    `mapstructure:"db"`
+	// 部署模式: standalone-单节点, sentinel-哨兵(主从自动切换), cluster-集群
+	Mode string `mapstructure:"mode"`
+	Addr string `mapstructure:"addr"` // 单节点的地址
+	// 哨兵模式下为哨兵的地址, 集群模式下为集群节点的地址, 不需要列出全部节点
+	Addrs            []string `mapstructure:"addrs"`
+	MasterName       string   `mapstructure:"master_name"` // 哨兵模式下监控的主节点名称
+	Username         string   `mapstructure:"username"`
+	Password         string   `mapstructure:"password"`
+	SentinelPassword string   `mapstructure:"sentinel_password"`
+	DB               int      `mapstructure:"db"` // 集群模式只有0号库
+	PoolSize         int      `mapstructure:"pool_size"`
+	// 超时和重试, 不设置时使用go-redis的默认值, MaxRetries 为-1时不重试
+	DialTimeout     time.Duration `mapstructure:"dial_timeout"`
+	ReadTimeout     time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
+	PoolTimeout     time.Duration `mapstructure:"pool_timeout"`
+	MaxRetries      int           `mapstructure:"max_retries"`
+	MinRetryBackoff time.Duration `mapstructure:"min_retry_backoff"`
+	MaxRetryBackoff time.Duration `mapstructure:"max_retry_backoff"`
+	TLS             RedisTLS      `mapstructure:"tls"`
+	// 升级到带hash tag的会话key后, 在一个RefreshToken有效期内继续读取旧key中的会话, 之后关闭
+	LegacySessionKeys bool `mapstructure:"legacy_session_keys"`
+}
+
+type RedisTLS struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	CAFile     string `mapstructure:"ca_file"`   // 为空时使用系统的根证书
+	CertFile   string `mapstructure:"cert_file"` // Redis要求客户端证书时配置
+	KeyFile    string `mapstructure:"key_file"`
+	ServerName string `mapstructure:"server_name"`
+}
+
+// 登录风控配置
+type riskConfig struct {
+	StepUpScore      int           `mapstructure:"step_up_score"`      // 评分达到此值需要二次验证
+	DenyScore        int           `mapstructure:"deny_score"`         // 评分达到此值直接拒绝登录
+	FailureWindow    time.Duration `mapstructure:"failure_window"`     // 统计登录失败次数的时间窗口
+	FailureThreshold int64         `mapstructure:"failure_threshold"`  // 窗口内失败次数达到此值算作风险
+	MaxTravelSpeed   float64       `mapstructure:"max_travel_speed"`   // 两次登录间可能的最大移动速度(km/h)
+	BadIps           []string      `mapstructure:"bad_ips"`            // 配置文件中的IP黑名单, Redis中还有一份可动态维护的黑名单
+	GeoLookupTimeout time.Duration `mapstructure:"geo_lookup_timeout"` // 查询IP归属地的超时时间, 超时后不计算地理位置相关的风险项
+	GeoCacheTTL      time.Duration `mapstructure:"geo_cache_ttl"`      // IP归属地在本地缓存的时间
+	GeoCacheEntries  int           `mapstructure:"geo_cache_entries"`  // 本地最多缓存多少个IP的归属地
+	Weights          struct {
+		NewCountry       int `mapstructure:"new_country"`
+		NewDevice        int `mapstructure:"new_device"`
+		ImpossibleTravel int `mapstructure:"impossible_travel"`
+		BadIp            int `mapstructure:"bad_ip"`
+		TooManyFailures  int `mapstructure:"too_many_failures"`
+	} `mapstructure:"weights"`
+}
+
+// setDefaults 补全没有配置的风控参数, 没有配置 risk 时所有风险项都不计分
+func (rc *riskConfig) setDefaults() {
+	if rc.StepUpScore <= 0 {
+		rc.StepUpScore = 40
+	}
+	if rc.DenyScore <= 0 {
+		// 评分从0开始累加, 拒绝分数为0会拒绝所有登录
+		rc.DenyScore = 80
+	}
+	if rc.FailureWindow <= 0 {
+		rc.FailureWindow = 15 * time.Minute
+	}
+	if rc.MaxTravelSpeed <= 0 {
+		rc.MaxTravelSpeed = 1000
+	}
+	if rc.GeoLookupTimeout <= 0 {
+		rc.GeoLookupTimeout = 500 * time.Millisecond
+	}
+	if rc.GeoCacheTTL <= 0 {
+		rc.GeoCacheTTL = time.Hour
+	}
+	if rc.GeoCacheEntries <= 0 {
+		rc.GeoCacheEntries = 10000
+	}
+}
+
+func (rc *riskConfig) validate() error {
+	if rc.StepUpScore > rc.DenyScore {
+		return fmt.Errorf("step_up_score %d is greater than deny_score %d", rc.StepUpScore, rc.DenyScore)
+	}
+	return nil
+}
+
+// 短信、邮件验证码配置
+type verifyCodeConfig struct {
+	Length         uint8         `mapstructure:"length"`          // 验证码位数
+	TTL            time.Duration `mapstructure:"ttl"`             // 验证码有效期
+	ResendCooldown time.Duration `mapstructure:"resend_cooldown"` // 重新发送的冷却时间
+	MaxAttempts    int64         `mapstructure:"max_attempts"`    // 验证码最多能被校验的次数
+}
+
+func (vc *verifyCodeConfig) setDefaults() {
+	if vc.Length == 0 {
+		vc.Length = 6
+	}
+	if vc.TTL <= 0 {
+		vc.TTL = 5 * time.Minute
+	}
+	if vc.ResendCooldown <= 0 {
+		vc.ResendCooldown = time.Minute
+	}
+	if vc.MaxAttempts <= 0 {
+		vc.MaxAttempts = 5
+	}
+}
+
+// 短信服务配置
+type smsConfig struct {
+	Provider string `mapstructure:"provider"`  // 短信服务商, log-只记日志不真正发送
+	SignName string `mapstructure:"sign_name"` // 短信签名
+}
+
+// 邮件服务配置
+type mailConfig struct {
+	Provider      string `mapstructure:"provider"`       // smtp-通过SMTP服务器发送, capture-只保存在内存中不真正发送
+	Host          string `mapstructure:"host"`           // SMTP服务器地址
+	Port          int    `mapstructure:"port"`           // SMTP服务器端口
+	Username      string `mapstructure:"username"`       // SMTP认证用户名
+	Password      string `mapstructure:"password"`       // SMTP认证密码
+	SSL           bool   `mapstructure:"ssl"`            // 是否直接使用TLS连接(465端口), 否则在服务器支持时使用STARTTLS
+	From          string `mapstructure:"from"`           // 发件人地址
+	FromName      string `mapstructure:"from_name"`      // 发件人名称
+	DefaultLocale string `mapstructure:"default_locale"` // 客户端语言没有对应的邮件模板时使用的语言
+}
+
+// 找回密码配置
+type passwordResetConfig struct {
+	TokenTTL       time.Duration `mapstructure:"token_ttl"`       // 重置密码Token的有效期
+	ResendCooldown time.Duration `mapstructure:"resend_cooldown"` // 同一账号重新申请的冷却时间
+	Url            string        `mapstructure:"url"`             // 前端重置密码页面的地址, Token会作为token参数拼接到地址上
+}
+
+func (pc *passwordResetConfig) setDefaults() {
+	if pc.TokenTTL <= 0 {
+		pc.TokenTTL = 30 * time.Minute
+	}
+	if pc.ResendCooldown <= 0 {
+		pc.ResendCooldown = time.Minute
+	}
+}
+
+// validate 没有前端页面地址时发出的重置密码邮件无法使用
+func (pc *passwordResetConfig) validate() error {
+	if pc.Url == "" {
+		return fmt.Errorf("url is required")
+	}
+	return nil
+}
+
+// 密码策略配置
+type passwordPolicyConfig struct {
+	MinLength         int    `mapstructure:"min_length"`          // 最小长度
+	MaxLength         int    `mapstructure:"max_length"`          // 最大长度, bcrypt 只使用密码的前72个字节, 不要超过72
+	RequireLower      bool   `mapstructure:"require_lower"`       // 必须包含小写字母
+	RequireUpper      bool   `mapstructure:"require_upper"`       // 必须包含大写字母
+	RequireDigit      bool   `mapstructure:"require_digit"`       // 必须包含数字
+	RequireSymbol     bool   `mapstructure:"require_symbol"`      // 必须包含特殊字符
+	RejectLoginName   bool   `mapstructure:"reject_login_name"`   // 不能包含登录名
+	HistorySize       int    `mapstructure:"history_size"`        // 不能与最近几次使用过的密码相同, 0-不限制
+	BreachedCorpusDir string `mapstructure:"breached_corpus_dir"` // 泄露密码库目录, 为空时不检查
+	BreachedMinCount  int    `mapstructure:"breached_min_count"`  // 在泄露密码库中出现次数达到此值时拒绝使用
+}
+
+func (pc *passwordPolicyConfig) setDefaults() {
+	if pc.MinLength <= 0 {
+		pc.MinLength = 8
+	}
+	if pc.MaxLength <= 0 {
+		pc.MaxLength = 64
+	}
+	if pc.BreachedMinCount <= 0 {
+		pc.BreachedMinCount = 1
+	}
+}
+
+func (pc *passwordPolicyConfig) validate() error {
+	if pc.MaxLength > 72 {
+		return fmt.Errorf("max_length %d exceeds the 72 bytes used by bcrypt", pc.MaxLength)
+	}
+	if pc.MinLength > pc.MaxLength {
+		return fmt.Errorf("min_length %d is greater than max_length %d", pc.MinLength, pc.MaxLength)
+	}
+	return nil
+}
+
+// H5 邮件登录链接配置
+type magicLinkConfig struct {
+	SignKey        string        `mapstructure:"sign_key"`        // 链接签名密钥
+	TTL            time.Duration `mapstructure:"ttl"`             // 链接有效期
+	ResendCooldown time.Duration `mapstructure:"resend_cooldown"` // 同一邮箱重新发送的冷却时间
+	Url            string        `mapstructure:"url"`             // 前端登录页面的地址, Token会作为token参数拼接到地址上
+	BindDevice     bool          `mapstructure:"bind_device"`     // 是否只允许在申请链接的设备(浏览器)上使用链接登录
+}
+
+func (mc *magicLinkConfig) setDefaults() {
+	if mc.TTL <= 0 {
+		mc.TTL = 10 * time.Minute
+	}
+	if mc.ResendCooldown <= 0 {
+		mc.ResendCooldown = time.Minute
+	}
+}
+
+// validate 签名密钥为空时任何人都可以伪造登录链接
+func (mc *magicLinkConfig) validate() error {
+	if mc.SignKey == "" {
+		return fmt.Errorf("sign_key is required")
+	}
+	if mc.Url == "" {
+		return fmt.Errorf("url is required")
+	}
+	return nil
+}
+
+// PC扫码登录配置
+type qrLoginConfig struct {
+	TTL            time.Duration `mapstructure:"ttl"`             // 二维码有效期
+	StreamInterval time.Duration `mapstructure:"stream_interval"` // 通过SSE推送状态时查询状态的间隔
+}
+
+func (qc *qrLoginConfig) setDefaults() {
+	if qc.TTL <= 0 {
+		qc.TTL = 2 * time.Minute
+	}
+	if qc.StreamInterval <= 0 {
+		qc.StreamInterval = time.Second
+	}
+}
+
+// OAuth 2.0 设备授权(RFC 8628)配置, 用于智能电视、命令行工具等不方便输入的设备登录
+type deviceAuthConfig struct {
+	TTL             time.Duration  `mapstructure:"ttl"`              // device_code 和 user_code 的有效期
+	Interval        time.Duration  `mapstructure:"interval"`         // 设备轮询Token的最小间隔
+	VerificationUri string         `mapstructure:"verification_uri"` // 用户输入 user_code 的页面地址
+	Clients         []DeviceClient `mapstructure:"clients"`          // 允许使用设备授权的客户端
+}
+
+// setDefaults 没有配置 device_auth 时没有客户端可以使用设备授权
+func (dc *deviceAuthConfig) setDefaults() {
+	if dc.TTL <= 0 {
+		dc.TTL = 10 * time.Minute
+	}
+	if dc.Interval <= 0 {
+		dc.Interval = 5 * time.Second
+	}
+}
+
+type DeviceClient struct {
+	ClientId string   `mapstructure:"client_id"`
+	Name     string   `mapstructure:"name"`     // 在授权页面展示给用户的名称
+	Platform string   `mapstructure:"platform"` // 授权后登录的平台
+	Scopes   []string `mapstructure:"scopes"`   // 客户端可以申请的scope
+}
+
+// OAuth 2.0 Token交换(RFC 8693)配置, 用于后端服务之间代表用户调用
+type tokenExchangeConfig struct {
+	TTL     time.Duration    `mapstructure:"ttl"`     // 交换出的Token的有效期
+	Clients []ExchangeClient `mapstructure:"clients"` // 允许交换Token的后端服务
+}
+
+// setDefaults 没有配置 token_exchange 时没有后端服务可以交换Token
+func (tc *tokenExchangeConfig) setDefaults() {
+	if tc.TTL <= 0 {
+		tc.TTL = 5 * time.Minute
+	}
+}
+
+type ExchangeClient struct {
+	ClientId string `mapstructure:"client_id"`
+	// 密钥的SHA-256(十六进制小写), 配置文件中不保存密钥明文, 可以用 echo -n '<secret>' | sha256sum 生成
+	SecretHash string   `mapstructure:"secret_sha256"`
+	Audiences  []string `mapstructure:"audiences"` // 可以为哪些目标服务交换Token
+	Scopes     []string `mapstructure:"scopes"`    // 交换出的Token可以申请的scope
+}
+
+// 机器客户端使用的 API Key 配置
+type apiKeyConfig struct {
+	MaxPerUser   int           `mapstructure:"max_per_user"`   // 每个用户最多可以创建的API Key数量
+	Scopes       []string      `mapstructure:"scopes"`         // API Key可以申请的scope
+	CacheTTL     time.Duration `mapstructure:"cache_ttl"`      // API Key校验结果的缓存时间, 吊销时会立即删除缓存
+	AdminUserIds []int64       `mapstructure:"admin_user_ids"` // 可以管理其他用户API Key的管理员
+}
+
+// setDefaults 没有配置 api_key 时用户可以创建API Key, 但不能申请任何scope
+func (ac *apiKeyConfig) setDefaults() {
+	if ac.MaxPerUser <= 0 {
+		ac.MaxPerUser = 10
+	}
+	if ac.CacheTTL <= 0 {
+		ac.CacheTTL = 5 * time.Minute
+	}
+}
+
+// 内部服务间调用的请求签名配置
+type requestSignConfig struct {
+	MaxSkew      time.Duration `mapstructure:"max_skew"`       // 请求时间戳与服务器时间允许的最大偏差, 也是随机数防重放的记录时间
+	MaxBodyBytes int64         `mapstructure:"max_body_bytes"` // 校验签名时读取的请求体大小上限, 超过时拒绝请求
+	Keys         []SignKey     `mapstructure:"keys"`           // 分配给内部服务的签名密钥
+}
+
+// setDefaults 补全没有配置的签名参数
+func (rc *requestSignConfig) setDefaults() {
+	if rc.MaxSkew <= 0 {
+		rc.MaxSkew = 5 * time.Minute
+	}
+	if rc.MaxBodyBytes <= 0 {
+		rc.MaxBodyBytes = 1 << 20
+	}
+}
+
+type SignKey struct {
+	KeyId   string `mapstructure:"key_id"`
+	Secret  string `mapstructure:"secret"`
+	Service string `mapstructure:"service"` // 使用密钥的服务名
+}
+
+// HTTP服务的TLS配置, 配置了客户端CA时内部服务可以使用客户端证书认证(mTLS)
+type serverTLSConfig struct {
+	Enabled      bool            `mapstructure:"enabled"`
+	CertFile     string          `mapstructure:"cert_file"`
+	KeyFile      string          `mapstructure:"key_file"`
+	ClientCAFile string          `mapstructure:"client_ca_file"` // 签发客户端证书的CA, 可以包含多个证书
+	ClientAuth   string          `mapstructure:"client_auth"`    // 面向外部的服务: none-不校验, request-提供了证书时校验
+	InternalAddr string          `mapstructure:"internal_addr"`  // 只提供内部接口的mTLS监听地址, 必须提供客户端证书, 配置后 /internal 路由只在这个地址上提供
+	Principals   []CertPrincipal `mapstructure:"principals"`     // 客户端证书与内部服务的对应关系
+}
+
+// setDefaults 没有配置 server_tls 时不开启TLS
+func (sc *serverTLSConfig) setDefaults() {}
+
+// CertPrincipal 证书的 Subject CommonName 或者 SAN(DNS、URI) 匹配其中任意一项即认为是该服务
+type CertPrincipal struct {
+	Service     string   `mapstructure:"service"`
+	CommonNames []string `mapstructure:"common_names"`
+	Sans        []string `mapstructure:"sans"`
+}
+
+// Web端使用Cookie保存Token的配置, 客户端在登录和刷新Token时通过请求头 go-mall-session-mode: cookie 选择使用
+type sessionCookieConfig struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	AccessCookie  string `mapstructure:"access_cookie"`
+	RefreshCookie string `mapstructure:"refresh_cookie"`
+	RefreshPath   string `mapstructure:"refresh_path"` // RefreshToken的Cookie只在刷新接口中携带
+	Domain        string `mapstructure:"domain"`
+	Secure        bool   `mapstructure:"secure"`
+	SameSite      string `mapstructure:"same_site"` // lax, strict, none
+	CsrfCookie    string `mapstructure:"csrf_cookie"`
+	CsrfHeader    string `mapstructure:"csrf_header"`
+}
+
+// setDefaults 没有配置 session_cookie 时不开启Cookie模式, 开启时补全Cookie的名称
+func (sc *sessionCookieConfig) setDefaults() {
+	if sc.AccessCookie == "" {
+		sc.AccessCookie = "go-mall-token"
+	}
+	if sc.RefreshCookie == "" {
+		sc.RefreshCookie = "go-mall-refresh-token"
+	}
+	if sc.RefreshPath == "" {
+		sc.RefreshPath = "/user/token/refresh"
+	}
+	if sc.SameSite == "" {
+		sc.SameSite = "lax"
+	}
+	if sc.CsrfCookie == "" {
+		sc.CsrfCookie = "go-mall-csrf"
+	}
+	if sc.CsrfHeader == "" {
+		sc.CsrfHeader = "X-CSRF-Token"
+	}
+}
+
+// 认证中间件的配置
+type authConfig struct {
+	// 按顺序从请求中读取Token, 取第一个读到的Token:
+	// bearer-Authorization: Bearer 请求头, header-go-mall-token 请求头, api_key_header-go-mall-api-key 请求头,
+	// cookie-Cookie模式的Cookie, query-WebSocket握手请求的查询参数
+	TokenExtractors []string `mapstructure:"token_extractors"`
+	QueryParam      string   `mapstructure:"query_param"` // WebSocket握手请求中携带Token的查询参数
+	// 网关转发认证(/auth/verify)在本地缓存验证通过的结果, 减少对Redis的访问, 收到吊销事件时缓存立即失效, 事件丢失时最多延迟这么久生效
+	ForwardAuthCacheTTL     time.Duration `mapstructure:"forward_auth_cache_ttl"`
+	ForwardAuthCacheEntries int           `mapstructure:"forward_auth_cache_entries"`
+	// 登录会话的存储: redis-多节点共享, memory-保存在进程内存中, 只适合单节点的开发环境
+	// memory 只把会话和刷新Token用的锁放在内存中, 验证码、限流、API Key缓存、请求签名防重放、吊销事件仍然需要Redis,
+	// 启动时同样检查Redis连接, 连不上时启动失败
+	SessionStore string `mapstructure:"session_store"`
+}
+
+// tokenExtractorNames 认证中间件支持的Token读取方式
+var tokenExtractorNames = map[string]bool{
+	"bearer": true, "header": true, "api_key_header": true, "cookie": true, "query": true,
+}
+
+// setDefaults 没有配置读取链时从请求头和Cookie读取Token
+func (ac *authConfig) setDefaults() {
+	if len(ac.TokenExtractors) == 0 {
+		ac.TokenExtractors = []string{"bearer", "header", "api_key_header", "cookie"}
+	}
+}
+
+// validate 读取方式写错时启动失败, 而不是在请求时跳过这种读取方式
+func (ac *authConfig) validate() error {
+	for _, name := range ac.TokenExtractors {
+		if !tokenExtractorNames[name] {
+			return fmt.Errorf("unknown token_extractors: %s", name)
+		}
+		if name == "query" && ac.QueryParam == "" {
+			return fmt.Errorf("token_extractors query requires query_param")
+		}
+	}
+	return nil
+}
+
+// 提供给内部服务的gRPC服务配置, 与HTTP服务共用 server_tls 中的证书配置
+type grpcConfig struct {
+	Addr string `mapstructure:"addr"`
+}
+
+func (gc *grpcConfig) setDefaults() {
+	if gc.Addr == "" {
+		gc.Addr = ":9090"
+	}
 }
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/logic/do"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// SetApiKey 缓存API Key的校验结果, 避免每个请求都查询数据库
+func SetApiKey(ctx context.Context, keyHash string, apiKey *do.ApiKey, ttl time.Duration) error {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_API_KEY, keyHash)
+	apiKeyDataBytes, _ := json.Marshal(apiKey)
+	return Redis().Set(ctx, redisKey, apiKeyDataBytes, ttl).Err()
+}
+
+// GetApiKey 获取缓存的API Key, 缓存不存在时返回nil
+func GetApiKey(ctx context.Context, keyHash string) (*do.ApiKey, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_API_KEY, keyHash)
+	result, err := Redis().Get(ctx, redisKey).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	apiKey := new(do.ApiKey)
+	if err = json.Unmarshal([]byte(result), apiKey); err != nil {
+		return nil, err
+	}
+	return apiKey, nil
+}
+
+// DelApiKey 轮换或吊销API Key时删除缓存, 让旧Key立即失效
+func DelApiKey(ctx context.Context, keyHash string) error {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_API_KEY, keyHash)
+	return Redis().Del(ctx, redisKey).Err()
+}
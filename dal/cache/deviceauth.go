@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/logic/do"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+// 设备授权请求存在Hash中, Key为device_code的哈希, user_code 单独存一个Key指向它
+const (
+	deviceAuthClientField   = "client_id"
+	deviceAuthScopeField    = "scope"
+	deviceAuthUserCodeField = "user_code"
+	deviceAuthStatusField   = "status"
+	deviceAuthUserField     = "user_id"
+	deviceAuthIntervalField = "interval"  // 当前要求的轮询间隔(秒), 轮询过快时会增加
+	deviceAuthLastPollField = "last_poll" // 上次轮询的时间(毫秒)
+)
+
+// 只有待授权的请求才能被用户同意或拒绝
+var decideDeviceAuthScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'status') ~= ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'status', ARGV[2], 'user_id', ARGV[3])
+return 1
+`)
+
+// 设备轮询: 校验client_id, 检查轮询间隔, 已同意的请求返回授权用户后立即删除, 保证只发放一次Token
+// ARGV[1]: client_id, ARGV[2]: 当前时间(毫秒), ARGV[3]: 轮询过快时增加的间隔(秒)
+var pollDeviceAuthScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return {'expired', 0, ''}
+end
+if redis.call('HGET', KEYS[1], 'client_id') ~= ARGV[1] then
+	return {'invalid_client', 0, ''}
+end
+local interval = tonumber(redis.call('HGET', KEYS[1], 'interval'))
+local lastPoll = tonumber(redis.call('HGET', KEYS[1], 'last_poll') or '0')
+local now = tonumber(ARGV[2])
+redis.call('HSET', KEYS[1], 'last_poll', now)
+if lastPoll > 0 and now - lastPoll < interval * 1000 then
+	redis.call('HSET', KEYS[1], 'interval', interval + tonumber(ARGV[3]))
+	return {'slow_down', 0, ''}
+end
+local status = redis.call('HGET', KEYS[1], 'status')
+local userId = redis.call('HGET', KEYS[1], 'user_id')
+local scope = redis.call('HGET', KEYS[1], 'scope')
+if status == 'approved' then
+	redis.call('DEL', KEYS[1])
+end
+return {status, tonumber(userId), scope}
+`)
+
+// SetDeviceAuthorization 保存设备授权请求, user_code 已被占用时返回false
+func SetDeviceAuthorization(ctx context.Context, deviceCodeHash string, auth *do.DeviceAuthorization,
+	interval, ttl time.Duration) (bool, error) {
+	userCodeKey := fmt.Sprintf(enum.REDIS_KEY_DEVICE_USER_CODE, auth.UserCode)
+	ok, err := Redis().SetNX(ctx, userCodeKey, deviceCodeHash, ttl).Result()
+	if err != nil || !ok {
+		return false, err
+	}
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_DEVICE_CODE, deviceCodeHash)
+	pipe := Redis().TxPipeline()
+	pipe.HSet(ctx, redisKey,
+		deviceAuthClientField, auth.ClientId,
+		deviceAuthScopeField, auth.Scope,
+		deviceAuthUserCodeField, auth.UserCode,
+		deviceAuthStatusField, enum.DeviceAuthStatusPending,
+		deviceAuthUserField, 0,
+		deviceAuthIntervalField, int64(interval.Seconds()),
+		deviceAuthLastPollField, 0,
+	)
+	pipe.Expire(ctx, redisKey, ttl)
+	_, err = pipe.Exec(ctx)
+	return err == nil, err
+}
+
+// GetDeviceAuthorizationByUserCode 通过 user_code 获取设备授权请求, 不存在或已过期时返回nil
+func GetDeviceAuthorizationByUserCode(ctx context.Context, userCode string) (*do.DeviceAuthorization, string, error) {
+	userCodeKey := fmt.Sprintf(enum.REDIS_KEY_DEVICE_USER_CODE, userCode)
+	deviceCodeHash, err := Redis().Get(ctx, userCodeKey).Result()
+	if err == redis.Nil {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_DEVICE_CODE, deviceCodeHash)
+	result, err := Redis().HGetAll(ctx, redisKey).Result()
+	if err != nil {
+		return nil, "", err
+	}
+	if len(result) == 0 {
+		return nil, "", nil
+	}
+	userId, _ := strconv.ParseInt(result[deviceAuthUserField], 10, 64)
+	return &do.DeviceAuthorization{
+		ClientId: result[deviceAuthClientField],
+		Scope:    result[deviceAuthScopeField],
+		UserCode: result[deviceAuthUserCodeField],
+		Status:   result[deviceAuthStatusField],
+		UserId:   userId,
+	}, deviceCodeHash, nil
+}
+
+// DecideDeviceAuthorization 用户同意或拒绝设备授权请求, 请求不是待授权状态时返回false
+func DecideDeviceAuthorization(ctx context.Context, deviceCodeHash, status string, userId int64) (bool, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_DEVICE_CODE, deviceCodeHash)
+	res, err := decideDeviceAuthScript.Run(ctx, Redis(), []string{redisKey},
+		enum.DeviceAuthStatusPending, status, userId).Int()
+	return res == 1, err
+}
+
+// PollDeviceAuthorization 设备轮询授权结果
+// result 为 expired, invalid_client, slow_down 或者请求的状态, 状态为 approved 时返回授权的用户和scope
+func PollDeviceAuthorization(ctx context.Context, deviceCodeHash, clientId string, slowDownStep time.Duration) (result string, userId int64, scope string, err error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_DEVICE_CODE, deviceCodeHash)
+	res, err := pollDeviceAuthScript.Run(ctx, Redis(), []string{redisKey},
+		clientId, time.Now().UnixMilli(), int64(slowDownStep.Seconds())).Slice()
+	if err != nil {
+		return "", 0, "", err
+	}
+	result, _ = res[0].(string)
+	userId, _ = res[1].(int64)
+	scope, _ = res[2].(string)
+	return result, userId, scope, nil
+}
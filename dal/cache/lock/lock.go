@@ -0,0 +1,184 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/ljinf/user_auth/common/logger"
+	"sync"
+	"time"
+)
+
+// 分布式锁, 每次加锁生成随机的owner token, 解锁和续期时校验token, 不会误删其他请求持有的锁
+// 默认只尝试一次加锁, WithWait 可以在Context超时前阻塞重试, WithWatchdog 在持有期间自动续期
+
+var (
+	// ErrNotObtained 锁已被其他请求持有, 阻塞加锁时表示在Context结束前没有拿到锁
+	ErrNotObtained = errors.New("lock: not obtained")
+	// ErrNotHeld 锁已过期或者已被其他请求持有
+	ErrNotHeld = errors.New("lock: not held")
+)
+
+// backend 锁的存储, 所有操作都要求是原子的
+type backend interface {
+	// acquire key不存在时设置为token, 返回是否设置成功
+	acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	// release key的值为token时删除, 返回是否删除
+	release(ctx context.Context, key, token string) (bool, error)
+	// refresh key的值为token时重新设置有效期, 返回是否设置成功
+	refresh(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+}
+
+// Client 创建锁, 通过 New 使用Redis存储, 通过 NewMemory 在进程内存中存储
+type Client struct {
+	backend backend
+}
+
+type obtainOption struct {
+	waitInterval time.Duration
+	watchdog     bool
+}
+
+type Option interface {
+	apply(option *obtainOption)
+}
+
+type optionFunc func(option *obtainOption)
+
+func (f optionFunc) apply(opts *obtainOption) {
+	f(opts)
+}
+
+// WithWait 锁被占用时每隔interval重试一次, 直到拿到锁或者Context结束
+func WithWait(interval time.Duration) Option {
+	return optionFunc(func(opts *obtainOption) {
+		opts.waitInterval = interval
+	})
+}
+
+// WithWatchdog 持有锁期间每隔ttl的1/3自动续期, 用于执行时间可能超过ttl的操作
+func WithWatchdog() Option {
+	return optionFunc(func(opts *obtainOption) {
+		opts.watchdog = true
+	})
+}
+
+// Obtain 对key加锁, ttl为锁的有效期, 锁被其他请求持有时返回 ErrNotObtained
+func (c *Client) Obtain(ctx context.Context, key string, ttl time.Duration, options ...Option) (*Lock, error) {
+	opts := &obtainOption{}
+	for _, opt := range options {
+		opt.apply(opts)
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		ok, err := c.backend.acquire(ctx, key, token, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			break
+		}
+		if opts.waitInterval <= 0 {
+			return nil, ErrNotObtained
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ErrNotObtained
+		case <-time.After(opts.waitInterval):
+		}
+	}
+
+	l := &Lock{backend: c.backend, key: key, token: token, ttl: ttl}
+	if opts.watchdog {
+		l.startWatchdog(ctx)
+	}
+	return l, nil
+}
+
+// Lock 已经拿到的锁
+type Lock struct {
+	backend backend
+	key     string
+	token   string
+	ttl     time.Duration
+
+	stopOnce     sync.Once
+	stopWatchdog chan struct{}
+}
+
+// Key 加锁的key
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Refresh 把锁的有效期重新设置为ttl, 锁已过期或被其他请求持有时返回 ErrNotHeld
+func (l *Lock) Refresh(ctx context.Context, ttl time.Duration) error {
+	ok, err := l.backend.refresh(ctx, l.key, l.token, ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// Release 释放锁, 只会删除自己持有的锁, 锁已过期或被其他请求持有时返回 ErrNotHeld
+func (l *Lock) Release(ctx context.Context) error {
+	l.stop()
+	ok, err := l.backend.release(ctx, l.key, l.token)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+func (l *Lock) startWatchdog(ctx context.Context) {
+	l.stopWatchdog = make(chan struct{})
+	interval := l.ttl / 3
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stopWatchdog:
+				return
+			case <-ticker.C:
+			}
+			// 请求的Context可能已经结束, 续期使用独立的Context
+			refreshCtx, cancel := context.WithTimeout(context.Background(), interval)
+			err := l.Refresh(refreshCtx, l.ttl)
+			cancel()
+			if err != nil {
+				logger.New().Warn(ctx, "lock watchdog refresh failed", "key", l.key, "err", err)
+				if errors.Is(err, ErrNotHeld) {
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (l *Lock) stop() {
+	if l.stopWatchdog == nil {
+		return
+	}
+	l.stopOnce.Do(func() {
+		close(l.stopWatchdog)
+	})
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,184 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type testClient struct {
+	*Client
+	// elapse 让已加的锁经过d, miniredis不会随真实时间过期key
+	elapse func(d time.Duration)
+}
+
+func newClients(t *testing.T) map[string]testClient {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return map[string]testClient{
+		"memory": {Client: NewMemory(), elapse: time.Sleep},
+		"redis":  {Client: New(client), elapse: mr.FastForward},
+	}
+}
+
+func TestLock(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(ctx context.Context, c testClient) error
+		want error
+	}{
+		{
+			name: "obtain and release",
+			run: func(ctx context.Context, c testClient) error {
+				l, err := c.Obtain(ctx, "k", time.Minute)
+				if err != nil {
+					return err
+				}
+				return l.Release(ctx)
+			},
+		},
+		{
+			name: "held by other",
+			run: func(ctx context.Context, c testClient) error {
+				if _, err := c.Obtain(ctx, "k", time.Minute); err != nil {
+					return fmt.Errorf("first obtain: %v", err)
+				}
+				_, err := c.Obtain(ctx, "k", time.Minute)
+				return err
+			},
+			want: ErrNotObtained,
+		},
+		{
+			name: "obtain after release",
+			run: func(ctx context.Context, c testClient) error {
+				l, err := c.Obtain(ctx, "k", time.Minute)
+				if err != nil {
+					return err
+				}
+				if err = l.Release(ctx); err != nil {
+					return err
+				}
+				_, err = c.Obtain(ctx, "k", time.Minute)
+				return err
+			},
+		},
+		{
+			name: "release twice",
+			run: func(ctx context.Context, c testClient) error {
+				l, err := c.Obtain(ctx, "k", time.Minute)
+				if err != nil {
+					return err
+				}
+				if err = l.Release(ctx); err != nil {
+					return err
+				}
+				return l.Release(ctx)
+			},
+			want: ErrNotHeld,
+		},
+		{
+			name: "release does not delete new owner",
+			run: func(ctx context.Context, c testClient) error {
+				l, err := c.Obtain(ctx, "k", 50*time.Millisecond)
+				if err != nil {
+					return err
+				}
+				c.elapse(100 * time.Millisecond)
+				if _, err = c.Obtain(ctx, "k", time.Minute); err != nil {
+					return fmt.Errorf("obtain expired lock: %v", err)
+				}
+				if err = l.Release(ctx); !errors.Is(err, ErrNotHeld) {
+					return fmt.Errorf("release expired lock: %v", err)
+				}
+				_, err = c.Obtain(ctx, "k", time.Minute)
+				return err
+			},
+			want: ErrNotObtained,
+		},
+		{
+			name: "wait until released",
+			run: func(ctx context.Context, c testClient) error {
+				l, err := c.Obtain(ctx, "k", time.Minute)
+				if err != nil {
+					return err
+				}
+				go func() {
+					time.Sleep(50 * time.Millisecond)
+					_ = l.Release(context.Background())
+				}()
+				_, err = c.Obtain(ctx, "k", time.Minute, WithWait(10*time.Millisecond))
+				return err
+			},
+		},
+		{
+			name: "wait until context done",
+			run: func(ctx context.Context, c testClient) error {
+				if _, err := c.Obtain(ctx, "k", time.Minute); err != nil {
+					return fmt.Errorf("first obtain: %v", err)
+				}
+				waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+				defer cancel()
+				_, err := c.Obtain(waitCtx, "k", time.Minute, WithWait(10*time.Millisecond))
+				return err
+			},
+			want: ErrNotObtained,
+		},
+		{
+			name: "watchdog keeps lock",
+			run: func(ctx context.Context, c testClient) error {
+				l, err := c.Obtain(ctx, "k", 60*time.Millisecond, WithWatchdog())
+				if err != nil {
+					return err
+				}
+				time.Sleep(150 * time.Millisecond)
+				if err = l.Refresh(ctx, time.Minute); err != nil {
+					return err
+				}
+				return l.Release(ctx)
+			},
+		},
+	}
+	for _, tt := range tests {
+		for backend, c := range newClients(t) {
+			t.Run(tt.name+"/"+backend, func(t *testing.T) {
+				if err := tt.run(context.Background(), c); !errors.Is(err, tt.want) {
+					t.Errorf("got %v, want %v", err, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestLockMutualExclusion(t *testing.T) {
+	for backend, c := range newClients(t) {
+		t.Run(backend, func(t *testing.T) {
+			var (
+				wg       sync.WaitGroup
+				mu       sync.Mutex
+				obtained int
+			)
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := c.Obtain(context.Background(), "k", time.Minute); err == nil {
+						mu.Lock()
+						obtained++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+			if obtained != 1 {
+				t.Errorf("obtained = %d, want 1", obtained)
+			}
+		})
+	}
+}
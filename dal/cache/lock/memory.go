@@ -0,0 +1,58 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryBackend struct {
+	mu    sync.Mutex
+	locks map[string]memoryLock
+}
+
+type memoryLock struct {
+	token    string
+	expireAt time.Time
+}
+
+// NewMemory 创建在进程内存中存储的锁, 只在当前进程内互斥, 用于单节点的开发环境和单元测试
+func NewMemory() *Client {
+	return &Client{backend: &memoryBackend{locks: make(map[string]memoryLock)}}
+}
+
+func (mb *memoryBackend) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	now := time.Now()
+	if l, ok := mb.locks[key]; ok && now.Before(l.expireAt) {
+		return false, nil
+	}
+	mb.locks[key] = memoryLock{token: token, expireAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (mb *memoryBackend) release(ctx context.Context, key, token string) (bool, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if !mb.held(key, token) {
+		return false, nil
+	}
+	delete(mb.locks, key)
+	return true, nil
+}
+
+func (mb *memoryBackend) refresh(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if !mb.held(key, token) {
+		return false, nil
+	}
+	mb.locks[key] = memoryLock{token: token, expireAt: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (mb *memoryBackend) held(key, token string) bool {
+	l, ok := mb.locks[key]
+	return ok && l.token == token && time.Now().Before(l.expireAt)
+}
@@ -0,0 +1,46 @@
+package lock
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// key的值为ARGV[1]时才删除, 避免删除其他请求持有的锁
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// key的值为ARGV[1]时才续期
+var refreshScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+type redisBackend struct {
+	client redis.UniversalClient
+}
+
+// New 创建使用Redis存储的锁, 多个节点之间互斥
+func New(client redis.UniversalClient) *Client {
+	return &Client{backend: &redisBackend{client: client}}
+}
+
+func (rb *redisBackend) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return rb.client.SetNX(ctx, key, token, ttl).Result()
+}
+
+func (rb *redisBackend) release(ctx context.Context, key, token string) (bool, error) {
+	res, err := releaseScript.Run(ctx, rb.client, []string{key}, token).Int64()
+	return res == 1, err
+}
+
+func (rb *redisBackend) refresh(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	res, err := refreshScript.Run(ctx, rb.client, []string{key}, token, ttl.Milliseconds()).Int64()
+	return res == 1, err
+}
@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/logic/do"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+func SetMagicLink(ctx context.Context, nonce string, link *do.MagicLink, ttl time.Duration) error {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_MAGIC_LINK, nonce)
+	linkDataBytes, _ := json.Marshal(link)
+	return Redis().Set(ctx, redisKey, linkDataBytes, ttl).Err()
+}
+
+// ConsumeMagicLink 取出并删除登录链接, 保证链接只能使用一次, 链接不存在时返回nil
+func ConsumeMagicLink(ctx context.Context, nonce string) (*do.MagicLink, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_MAGIC_LINK, nonce)
+	result, err := Redis().GetDel(ctx, redisKey).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	link := new(do.MagicLink)
+	if err = json.Unmarshal([]byte(result), link); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
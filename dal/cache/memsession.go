@@ -0,0 +1,243 @@
+package cache
+
+import (
+	"context"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/dal/cache/lock"
+	"github.com/ljinf/user_auth/logic/do"
+	"sync"
+	"time"
+)
+
+// memorySessionStore 在进程内存中存储会话, 只适合单节点的开发环境和单元测试, 进程重启后所有用户需要重新登录
+// 过期的Token在读取时判断, 写入时定期清理, RefreshToken过期的Session随之清理
+type memorySessionStore struct {
+	mu            sync.Mutex
+	accessTokens  map[string]*memorySessionItem
+	refreshTokens map[string]*memorySessionItem
+	userSessions  map[int64]map[string]*do.SessionInfo // userId -> platform -> session
+	refreshResult map[string]*memoryRefreshResult
+	lastSweep     time.Time
+	locker        *lock.Client
+}
+
+type memorySessionItem struct {
+	session  *do.SessionInfo
+	expireAt time.Time
+}
+
+type memoryRefreshResult struct {
+	tokenInfo do.TokenInfo
+	expireAt  time.Time
+}
+
+const memorySessionSweepInterval = time.Minute
+
+func NewMemorySessionStore() SessionStore {
+	return &memorySessionStore{
+		accessTokens:  make(map[string]*memorySessionItem),
+		refreshTokens: make(map[string]*memorySessionItem),
+		userSessions:  make(map[int64]map[string]*do.SessionInfo),
+		refreshResult: make(map[string]*memoryRefreshResult),
+		lastSweep:     time.Now(),
+		locker:        lock.NewMemory(),
+	}
+}
+
+func (ms *memorySessionStore) IssueSessionTokens(ctx context.Context, session *do.SessionInfo) (*do.SessionInfo, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.sweep()
+	now := time.Now()
+	ms.accessTokens[session.AccessToken] = &memorySessionItem{session: cloneSession(session), expireAt: now.Add(enum.AccessTokenDuration)}
+	ms.refreshTokens[session.RefreshToken] = &memorySessionItem{session: cloneSession(session), expireAt: now.Add(enum.RefreshTokenDuration)}
+	platforms, ok := ms.userSessions[session.UserId]
+	if !ok {
+		platforms = make(map[string]*do.SessionInfo)
+		ms.userSessions[session.UserId] = platforms
+	}
+	oldSession := platforms[session.Platform]
+	if oldSession != nil {
+		if oldSession.AccessToken != session.AccessToken {
+			delete(ms.accessTokens, oldSession.AccessToken)
+		}
+		if oldSession.RefreshToken != session.RefreshToken {
+			ms.delayDelRefreshToken(oldSession.RefreshToken)
+		}
+	}
+	platforms[session.Platform] = cloneSession(session)
+	return oldSession, nil
+}
+
+func (ms *memorySessionStore) GetAccessToken(ctx context.Context, accessToken string) (*do.SessionInfo, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.getToken(ms.accessTokens, accessToken), nil
+}
+
+func (ms *memorySessionStore) GetAccessTokenTTL(ctx context.Context, accessToken string) (time.Duration, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	item, ok := ms.accessTokens[accessToken]
+	if !ok {
+		return 0, nil
+	}
+	if ttl := time.Until(item.expireAt); ttl > 0 {
+		return ttl, nil
+	}
+	return 0, nil
+}
+
+func (ms *memorySessionStore) GetRefreshToken(ctx context.Context, refreshToken string) (*do.SessionInfo, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.getToken(ms.refreshTokens, refreshToken), nil
+}
+
+func (ms *memorySessionStore) DelAccessToken(ctx context.Context, accessToken string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.accessTokens, accessToken)
+	return nil
+}
+
+func (ms *memorySessionStore) DelRefreshToken(ctx context.Context, refreshToken string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.refreshTokens, refreshToken)
+	return nil
+}
+
+func (ms *memorySessionStore) DelayDelRefreshToken(ctx context.Context, refreshToken string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.delayDelRefreshToken(refreshToken)
+	return nil
+}
+
+func (ms *memorySessionStore) GetUserPlatformSession(ctx context.Context, userId int64, platform string) (*do.SessionInfo, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	session := ms.userSessions[userId][platform]
+	if session == nil {
+		return nil, nil
+	}
+	return cloneSession(session), nil
+}
+
+func (ms *memorySessionStore) GetUserAllSessions(ctx context.Context, userId int64) ([]*do.SessionInfo, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	sessions := make([]*do.SessionInfo, 0, len(ms.userSessions[userId]))
+	for _, session := range ms.userSessions[userId] {
+		sessions = append(sessions, cloneSession(session))
+	}
+	return sessions, nil
+}
+
+func (ms *memorySessionStore) DelUserPlatformSession(ctx context.Context, userId int64, platform string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	session := ms.userSessions[userId][platform]
+	if session == nil {
+		return nil
+	}
+	delete(ms.accessTokens, session.AccessToken)
+	delete(ms.refreshTokens, session.RefreshToken)
+	delete(ms.userSessions[userId], platform)
+	return nil
+}
+
+func (ms *memorySessionStore) DelUserAllSessions(ctx context.Context, userId int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for _, session := range ms.userSessions[userId] {
+		delete(ms.accessTokens, session.AccessToken)
+		delete(ms.refreshTokens, session.RefreshToken)
+	}
+	delete(ms.userSessions, userId)
+	return nil
+}
+
+func (ms *memorySessionStore) SetRefreshResult(ctx context.Context, refreshToken string, tokenInfo *do.TokenInfo, ttl time.Duration) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.refreshResult[refreshToken] = &memoryRefreshResult{tokenInfo: *tokenInfo, expireAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (ms *memorySessionStore) GetRefreshResult(ctx context.Context, refreshToken string) (*do.TokenInfo, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	result, ok := ms.refreshResult[refreshToken]
+	if !ok || !time.Now().Before(result.expireAt) {
+		return nil, nil
+	}
+	tokenInfo := result.tokenInfo
+	return &tokenInfo, nil
+}
+
+func (ms *memorySessionStore) Locker() *lock.Client {
+	return ms.locker
+}
+
+// getToken Token不存在或已过期时返回空的SessionInfo, 与Redis实现保持一致
+func (ms *memorySessionStore) getToken(tokens map[string]*memorySessionItem, token string) *do.SessionInfo {
+	item, ok := tokens[token]
+	if !ok || !time.Now().Before(item.expireAt) {
+		return new(do.SessionInfo)
+	}
+	return cloneSession(item.session)
+}
+
+func (ms *memorySessionStore) delayDelRefreshToken(refreshToken string) {
+	item, ok := ms.refreshTokens[refreshToken]
+	if !ok {
+		return
+	}
+	if expireAt := time.Now().Add(enum.OldRefreshTokenHoldingDuration); expireAt.Before(item.expireAt) {
+		item.expireAt = expireAt
+	}
+}
+
+// sweep 清理过期的Token, 调用方需要持有锁
+func (ms *memorySessionStore) sweep() {
+	now := time.Now()
+	if now.Sub(ms.lastSweep) < memorySessionSweepInterval {
+		return
+	}
+	ms.lastSweep = now
+	for _, tokens := range []map[string]*memorySessionItem{ms.accessTokens, ms.refreshTokens} {
+		for token, item := range tokens {
+			if !now.Before(item.expireAt) {
+				delete(tokens, token)
+			}
+		}
+	}
+	for token, result := range ms.refreshResult {
+		if !now.Before(result.expireAt) {
+			delete(ms.refreshResult, token)
+		}
+	}
+	// Session的RefreshToken已经过期, 用户只能重新登录, Session不再有用
+	for userId, platforms := range ms.userSessions {
+		for platform, session := range platforms {
+			if _, ok := ms.refreshTokens[session.RefreshToken]; !ok {
+				delete(platforms, platform)
+			}
+		}
+		if len(platforms) == 0 {
+			delete(ms.userSessions, userId)
+		}
+	}
+}
+
+// cloneSession 存取时都复制一份, 避免调用方修改存储中的数据
+func cloneSession(session *do.SessionInfo) *do.SessionInfo {
+	clone := *session
+	if session.Actor != nil {
+		actor := *session.Actor
+		clone.Actor = &actor
+	}
+	return &clone
+}
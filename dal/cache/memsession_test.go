@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ljinf/user_auth/logic/do"
+)
+
+func TestMemorySessionStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemorySessionStore().(*memorySessionStore)
+	expired := &do.SessionInfo{UserId: 1, Platform: "app", SessionId: "s1", AccessToken: "a1", RefreshToken: "r1"}
+	alive := &do.SessionInfo{UserId: 2, Platform: "app", SessionId: "s2", AccessToken: "a2", RefreshToken: "r2"}
+	for _, session := range []*do.SessionInfo{expired, alive} {
+		if _, err := ms.IssueSessionTokens(ctx, session); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 让用户1的Token全部过期, 并且到了下一次清理的时间
+	past := time.Now().Add(-time.Second)
+	ms.accessTokens["a1"].expireAt = past
+	ms.refreshTokens["r1"].expireAt = past
+	ms.lastSweep = time.Now().Add(-2 * memorySessionSweepInterval)
+
+	trigger := &do.SessionInfo{UserId: 3, Platform: "app", SessionId: "s3", AccessToken: "a3", RefreshToken: "r3"}
+	if _, err := ms.IssueSessionTokens(ctx, trigger); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		userId int64
+		want   int
+	}{
+		{name: "expired refresh token", userId: 1, want: 0},
+		{name: "alive", userId: 2, want: 1},
+		{name: "issued by sweep trigger", userId: 3, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions, err := ms.GetUserAllSessions(ctx, tt.userId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sessions) != tt.want {
+				t.Errorf("GetUserAllSessions(%d) = %d sessions, want %d", tt.userId, len(sessions), tt.want)
+			}
+		})
+	}
+	if _, ok := ms.userSessions[1]; ok {
+		t.Error("empty user session map not removed")
+	}
+	if _, ok := ms.accessTokens["a1"]; ok {
+		t.Error("expired access token not removed")
+	}
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// SetPasswordResetToken 保存重置密码Token的哈希, 用户之前申请的Token会失效
+// Token的key和用户的key都以userId作为hash tag, 集群模式下在同一个slot, 可以放在同一个事务中
+func SetPasswordResetToken(ctx context.Context, userId int64, tokenHash string, ttl time.Duration) error {
+	userKey := fmt.Sprintf(enum.REDIS_KEY_USER_PASSWORD_RESET, userId)
+	oldTokenHash, err := Redis().Get(ctx, userKey).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	pipe := Redis().TxPipeline()
+	if oldTokenHash != "" {
+		pipe.Del(ctx, fmt.Sprintf(enum.REDIS_KEY_PASSWORD_RESET_TOKEN, userId, oldTokenHash))
+	}
+	pipe.Set(ctx, fmt.Sprintf(enum.REDIS_KEY_PASSWORD_RESET_TOKEN, userId, tokenHash), userId, ttl)
+	pipe.Set(ctx, userKey, tokenHash, ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetPasswordResetToken 获取重置密码Token对应的用户, 不会让Token失效
+// userId 为从Token中解析出的用户, Token不存在或已过期时返回的userId为0
+func GetPasswordResetToken(ctx context.Context, userId int64, tokenHash string) (int64, error) {
+	storedUserId, err := Redis().Get(ctx, fmt.Sprintf(enum.REDIS_KEY_PASSWORD_RESET_TOKEN, userId, tokenHash)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return storedUserId, err
+}
+
+// ConsumePasswordResetToken 删除重置密码Token, 保证Token只能使用一次
+// Token不存在或已过期时返回false
+func ConsumePasswordResetToken(ctx context.Context, userId int64, tokenHash string) (bool, error) {
+	pipe := Redis().TxPipeline()
+	delToken := pipe.Del(ctx, fmt.Sprintf(enum.REDIS_KEY_PASSWORD_RESET_TOKEN, userId, tokenHash))
+	pipe.Del(ctx, fmt.Sprintf(enum.REDIS_KEY_USER_PASSWORD_RESET, userId))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return delToken.Val() == 1, nil
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/logic/do"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+// 二维码存在Hash中, 状态流转通过Lua脚本保证原子性
+const (
+	qrTicketStatusField = "status"
+	qrTicketUserField   = "user_id"
+	qrTicketSecretField = "secret_hash"
+)
+
+// 状态为ARGV[1]时才能变更为ARGV[2], ARGV[4]为1时还要求扫码的用户与ARGV[3]一致
+var transitQrTicketScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'status') ~= ARGV[1] then
+	return 0
+end
+if ARGV[4] == '1' and redis.call('HGET', KEYS[1], 'user_id') ~= ARGV[3] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'status', ARGV[2], 'user_id', ARGV[3])
+return 1
+`)
+
+// 已确认的二维码取出扫码用户后立即删除, 保证只会发放一次Token
+var consumeQrTicketScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'status') ~= ARGV[1] then
+	return 0
+end
+local userId = redis.call('HGET', KEYS[1], 'user_id')
+redis.call('DEL', KEYS[1])
+return tonumber(userId)
+`)
+
+func SetQrTicket(ctx context.Context, ticket, secretHash string, ttl time.Duration) error {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_QR_TICKET, ticket)
+	pipe := Redis().TxPipeline()
+	pipe.HSet(ctx, redisKey, qrTicketStatusField, enum.QrTicketStatusPending, qrTicketUserField, 0,
+		qrTicketSecretField, secretHash)
+	pipe.Expire(ctx, redisKey, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetQrTicket 获取二维码, 二维码不存在或已过期时返回nil
+func GetQrTicket(ctx context.Context, ticket string) (*do.QrTicket, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_QR_TICKET, ticket)
+	result, err := Redis().HGetAll(ctx, redisKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	userId, _ := strconv.ParseInt(result[qrTicketUserField], 10, 64)
+	return &do.QrTicket{
+		Status:     result[qrTicketStatusField],
+		UserId:     userId,
+		SecretHash: result[qrTicketSecretField],
+	}, nil
+}
+
+// TransitQrTicket 变更二维码状态, 二维码当前不是fromStatus状态时返回false
+// checkUser 为true时要求二维码的扫码用户与userId一致
+func TransitQrTicket(ctx context.Context, ticket, fromStatus, toStatus string, userId int64, checkUser bool) (bool, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_QR_TICKET, ticket)
+	checkUserArg := "0"
+	if checkUser {
+		checkUserArg = "1"
+	}
+	res, err := transitQrTicketScript.Run(ctx, Redis(), []string{redisKey},
+		fromStatus, toStatus, userId, checkUserArg).Int()
+	return res == 1, err
+}
+
+// ConsumeQrTicket 取出已确认二维码的扫码用户并删除二维码, 二维码不是已确认状态时返回0
+func ConsumeQrTicket(ctx context.Context, ticket string) (int64, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_QR_TICKET, ticket)
+	return consumeQrTicketScript.Run(ctx, Redis(), []string{redisKey}, enum.QrTicketStatusConfirmed).Int64()
+}
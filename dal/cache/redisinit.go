@@ -2,31 +2,113 @@ package cache
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
 	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/library"
 	"github.com/redis/go-redis/v9"
-	"time"
 )
 
-var redisClient *redis.Client
+var redisClient redis.UniversalClient
 
-func Redis() *redis.Client {
+// Redis 返回Redis客户端, 单节点、哨兵、集群模式都使用同一个接口
+// 集群模式下一个命令或脚本里的多个key需要在同一个slot中, 同一个用户的key使用 {userId} 作为hash tag
+func Redis() redis.UniversalClient {
 	return redisClient
 }
 
-func init() {
-	redisClient = redis.NewClient(&redis.Options{
-		Addr:         config.Redis.Addr,
-		Password:     config.Redis.Password,
-		DB:           config.Redis.DB,
-		PoolSize:     config.Redis.PoolSize,
-		DialTimeout:  10 * time.Second,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		PoolTimeout:  30 * time.Second,
-	})
-
-	if err := redisClient.Ping(context.Background()).Err(); err != nil {
-		// 连接不上redis 让项目停止启动
-		panic(err)
+// Close 关闭Redis连接池, 服务退出时调用
+func Close() error {
+	if redisClient == nil {
+		return nil
 	}
+	return redisClient.Close()
+}
+
+// Init 按配置连接Redis, 需要在 config.Load 之后调用
+func Init(ctx context.Context) error {
+	client, err := newRedisClient()
+	if err != nil {
+		return err
+	}
+	if err = client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return err
+	}
+	SetRedis(client)
+	return nil
+}
+
+// SetRedis 设置使用的Redis客户端, 测试中可以传入连接 miniredis 等内存实现的客户端
+func SetRedis(client redis.UniversalClient) {
+	redisClient = client
+}
+
+func newRedisClient() (redis.UniversalClient, error) {
+	conf := config.Redis
+	opts := &redis.UniversalOptions{
+		Addrs:            conf.Addrs,
+		MasterName:       conf.MasterName,
+		Username:         conf.Username,
+		Password:         conf.Password,
+		SentinelPassword: conf.SentinelPassword,
+		DB:               conf.DB,
+		PoolSize:         conf.PoolSize,
+		DialTimeout:      conf.DialTimeout,
+		ReadTimeout:      conf.ReadTimeout,
+		WriteTimeout:     conf.WriteTimeout,
+		PoolTimeout:      conf.PoolTimeout,
+		MaxRetries:       conf.MaxRetries,
+		MinRetryBackoff:  conf.MinRetryBackoff,
+		MaxRetryBackoff:  conf.MaxRetryBackoff,
+	}
+	if conf.TLS.Enabled {
+		tlsConfig, err := newRedisTLSConfig(conf.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts.TLSConfig = tlsConfig
+	}
+
+	switch conf.Mode {
+	case "", "standalone":
+		if len(opts.Addrs) == 0 {
+			opts.Addrs = []string{conf.Addr}
+		}
+		return redis.NewClient(opts.Simple()), nil
+	case "sentinel":
+		if conf.MasterName == "" || len(conf.Addrs) == 0 {
+			return nil, fmt.Errorf("redis sentinel mode requires master_name and addrs")
+		}
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case "cluster":
+		if len(conf.Addrs) == 0 {
+			return nil, fmt.Errorf("redis cluster mode requires addrs")
+		}
+		return redis.NewClusterClient(opts.Cluster()), nil
+	default:
+		return nil, fmt.Errorf("unknown redis mode: %s", conf.Mode)
+	}
+}
+
+func newRedisTLSConfig(conf config.RedisTLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: conf.ServerName,
+	}
+	if conf.CAFile != "" {
+		pool, err := library.LoadCertPool(conf.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if conf.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
 }
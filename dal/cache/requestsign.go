@@ -0,0 +1,14 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/ljinf/user_auth/common/enum"
+	"time"
+)
+
+// UseRequestNonce 记录签名请求使用过的随机数, 随机数已经使用过时返回false
+func UseRequestNonce(ctx context.Context, keyId, nonce string, ttl time.Duration) (bool, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_REQUEST_NONCE, keyId, nonce)
+	return Redis().SetNX(ctx, redisKey, 1, ttl).Result()
+}
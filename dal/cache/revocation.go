@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/logic/do"
+)
+
+// PublishSessionRevoked 发布会话被吊销的事件
+func PublishSessionRevoked(ctx context.Context, event *do.SessionRevokedEvent) error {
+	eventDataBytes, _ := json.Marshal(event)
+	return Redis().Publish(ctx, enum.REDIS_CHANNEL_SESSION_REVOKED, eventDataBytes).Err()
+}
+
+// SubscribeSessionRevoked 订阅会话被吊销的事件, 阻塞到ctx结束或者订阅的连接断开
+func SubscribeSessionRevoked(ctx context.Context, handle func(event *do.SessionRevokedEvent)) error {
+	pubsub := Redis().Subscribe(ctx, enum.REDIS_CHANNEL_SESSION_REVOKED)
+	defer pubsub.Close()
+	// 等待订阅成功, 连接失败时直接返回错误
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			event := new(do.SessionRevokedEvent)
+			if err := json.Unmarshal([]byte(msg.Payload), event); err != nil || event.UserId == 0 {
+				continue
+			}
+			handle(event)
+		}
+	}
+}
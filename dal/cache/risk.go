@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// 只在第一次失败时设置过期时间, 形成固定的统计窗口. 不使用 EXPIRE NX, 它需要 Redis 7 以上的版本
+var incrLoginFailureScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// IncrLoginFailure 用户登录失败次数加一, 计数在window时间后过期
+func IncrLoginFailure(ctx context.Context, userId int64, window time.Duration) (int64, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_LOGIN_FAILURE_COUNT, userId)
+	return incrLoginFailureScript.Run(ctx, Redis(), []string{redisKey}, window.Milliseconds()).Int64()
+}
+
+// GetLoginFailureCount 获取用户在统计窗口内的登录失败次数
+func GetLoginFailureCount(ctx context.Context, userId int64) (int64, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_LOGIN_FAILURE_COUNT, userId)
+	count, err := Redis().Get(ctx, redisKey).Int64()
+	if err != nil && err != redis.Nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ClearLoginFailure 登录成功后清除失败计数
+func ClearLoginFailure(ctx context.Context, userId int64) error {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_LOGIN_FAILURE_COUNT, userId)
+	return Redis().Del(ctx, redisKey).Err()
+}
+
+// IsBadIp IP是否在风控IP黑名单中, 黑名单由运营或者安全团队维护到Redis Set中
+func IsBadIp(ctx context.Context, ip string) (bool, error) {
+	return Redis().SIsMember(ctx, enum.REDIS_KEY_RISK_BAD_IPS, ip).Result()
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"github.com/ljinf/user_auth/dal/cache/lock"
+	"github.com/ljinf/user_auth/logic/do"
+	"time"
+)
+
+// SessionStore 用户登录会话和Token的存储
+// 查询Token时Token不存在返回 UserId 为0的SessionInfo, 查询用户Session时Session不存在返回nil
+type SessionStore interface {
+	// IssueSessionTokens 原子地完成一次Token发放: 设置新的AccessToken和RefreshToken, 删除用户在session.Platform平台
+	// 旧Session的AccessToken, 旧的RefreshToken延迟删除, 再用新Session覆盖旧Session. 返回被替换的旧Session, 没有时返回nil
+	IssueSessionTokens(ctx context.Context, session *do.SessionInfo) (*do.SessionInfo, error)
+
+	GetAccessToken(ctx context.Context, accessToken string) (*do.SessionInfo, error)
+	// GetAccessTokenTTL 查询AccessToken的剩余有效期, Token不存在时返回0
+	GetAccessTokenTTL(ctx context.Context, accessToken string) (time.Duration, error)
+	GetRefreshToken(ctx context.Context, refreshToken string) (*do.SessionInfo, error)
+	DelAccessToken(ctx context.Context, accessToken string) error
+	DelRefreshToken(ctx context.Context, refreshToken string) error
+	// DelayDelRefreshToken 让RefreshToken保留一段时间后自己过期
+	DelayDelRefreshToken(ctx context.Context, refreshToken string) error
+
+	GetUserPlatformSession(ctx context.Context, userId int64, platform string) (*do.SessionInfo, error)
+	GetUserAllSessions(ctx context.Context, userId int64) ([]*do.SessionInfo, error)
+	// DelUserPlatformSession 删除用户在指定平台的Session以及Session对应的Token
+	DelUserPlatformSession(ctx context.Context, userId int64, platform string) error
+	// DelUserAllSessions 删除用户在所有平台的Session以及Session对应的Token
+	DelUserAllSessions(ctx context.Context, userId int64) error
+
+	// SetRefreshResult 记录老的RefreshToken刷新得到的新Token, 在ttl内再次刷新时直接返回
+	SetRefreshResult(ctx context.Context, refreshToken string, tokenInfo *do.TokenInfo, ttl time.Duration) error
+	// GetRefreshResult 查询老的RefreshToken刷新得到的新Token, 不存在时返回nil
+	GetRefreshResult(ctx context.Context, refreshToken string) (*do.TokenInfo, error)
+
+	// Locker 与会话存储在同一处的锁, 刷新Token时防止同一个RefreshToken被并发刷新
+	Locker() *lock.Client
+}
+
+var sessionStore SessionStore
+
+// DefaultSessionStore 返回应用启动时设置的SessionStore, 由 bootstrap 按配置创建一次, 所有请求共用同一个实例
+func DefaultSessionStore() SessionStore {
+	return sessionStore
+}
+
+// SetSessionStore 设置应用使用的SessionStore
+func SetSessionStore(store SessionStore) {
+	sessionStore = store
+}
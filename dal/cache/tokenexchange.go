@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/logic/do"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// SetExchangedToken 保存Token交换得到的Token, 与用户登录的AccessToken分开存储,
+// 避免交换出的Token被当作普通登录Token访问所有接口
+func SetExchangedToken(ctx context.Context, token string, session *do.SessionInfo, ttl time.Duration) error {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_EXCHANGED_TOKEN, token)
+	sessionDataBytes, _ := json.Marshal(session)
+	return Redis().Set(ctx, redisKey, sessionDataBytes, ttl).Err()
+}
+
+// GetExchangedToken 获取交换得到的Token信息和剩余有效期, Token不存在时返回nil
+func GetExchangedToken(ctx context.Context, token string) (*do.SessionInfo, time.Duration, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_EXCHANGED_TOKEN, token)
+	pipe := Redis().Pipeline()
+	getCmd := pipe.Get(ctx, redisKey)
+	ttlCmd := pipe.TTL(ctx, redisKey)
+	_, err := pipe.Exec(ctx)
+	if err == redis.Nil {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	session := new(do.SessionInfo)
+	if err = json.Unmarshal([]byte(getCmd.Val()), session); err != nil {
+		return nil, 0, err
+	}
+	return session, ttlCmd.Val(), nil
+}
@@ -8,64 +8,158 @@ import (
 	"github.com/ljinf/user_auth/common/enum"
 	"github.com/ljinf/user_auth/common/errcode"
 	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/cache/lock"
 	"github.com/ljinf/user_auth/logic/do"
 	"github.com/redis/go-redis/v9"
 	"time"
 )
 
-// SetUserToken 设置用户的AccessToken 和 RefreshToken 缓存
-func SetUserToken(ctx context.Context, session *do.SessionInfo) error {
-	log := logger.New()
-	err := setAccessToken(ctx, session)
-	if err != nil {
-		log.Error(ctx, "redis error", "err", err)
-		return err
-	}
-	err = setRefreshToken(ctx, session)
-	if err != nil {
-		log.Error(ctx, "redis error", "err", err)
-		return err
+// redisSessionStore 使用Redis存储会话, 多个节点共享, 生产环境使用
+// AccessToken、RefreshToken 以Token为key各自存储, 用户在各平台的Session存储在以用户ID为key的Hash中
+// 这些key都以userId作为hash tag, 集群模式下同一个用户的key在同一个slot
+// 开启 redis.legacy_session_keys 时还会读取、删除加入hash tag之前的key, 新的Token只写入新key
+type redisSessionStore struct {
+	client redis.UniversalClient
+	locker *lock.Client
+}
+
+func NewRedisSessionStore(client redis.UniversalClient) SessionStore {
+	return &redisSessionStore{client: client, locker: lock.New(client)}
+}
+
+// legacyKeys 是否还需要读取加入hash tag之前的会话key
+func legacyKeys() bool {
+	return config.Redis != nil && config.Redis.LegacySessionKeys
+}
+
+// getWithLegacy 读取key, 不存在时再读取旧的key, 都不存在时返回 redis.Nil
+func (rs *redisSessionStore) getWithLegacy(ctx context.Context, key, legacyKey string) (string, error) {
+	result, err := rs.client.Get(ctx, key).Result()
+	if !errors.Is(err, redis.Nil) || !legacyKeys() {
+		return result, err
 	}
-	return err
+	return rs.client.Get(ctx, legacyKey).Result()
+}
+
+// accessTokenKey Token中包含了userId, 解析出userId作为key的hash tag, 伪造的Token解析出的userId为0
+func accessTokenKey(accessToken string) string {
+	userId, _ := util.ParseUserIdFromToken(accessToken)
+	return fmt.Sprintf(enum.REDIS_KEY_ACCESS_TOKEN, userId, accessToken)
 }
 
-func SetUserSession(ctx context.Context, session *do.SessionInfo) error {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_USER_SESSION, session.UserId)
-	sessionDataBytes, _ := json.Marshal(session)
-	err := Redis().HSet(ctx, redisKey, session.Platform, sessionDataBytes).Err()
+func refreshTokenKey(refreshToken string) string {
+	userId, _ := util.ParseUserIdFromToken(refreshToken)
+	return fmt.Sprintf(enum.REDIS_KEY_REFRESH_TOKEN, userId, refreshToken)
+}
+
+// 发放Token的脚本, 一次往返中完成: 设置新的AccessToken和RefreshToken, 删除同平台旧Session的AccessToken,
+// 让旧的RefreshToken延迟过期, 最后用新Session覆盖旧Session. 先读取并解析旧Session再写入, 旧Session解析失败时不会写入任何数据
+// KEYS[1] 新AccessToken KEYS[2] 新RefreshToken KEYS[3] 用户Session的Hash
+// ARGV[1] Session数据 ARGV[2] 平台 ARGV[3] AccessToken有效期 ARGV[4] RefreshToken有效期 ARGV[5] 旧RefreshToken保留时间(毫秒)
+// ARGV[6] AccessToken key前缀 ARGV[7] RefreshToken key前缀, 返回旧的Session数据, 没有旧Session时返回false
+// 旧Session的Token key在脚本中由前缀拼出, 没有在KEYS中声明. 删除旧AccessToken必须和覆盖Session原子地完成,
+// 但旧Token要读到Session之后才知道, 没法提前声明. 集群模式下能这样做完全依赖两个前缀与KEYS[3]带有相同的 {userId}
+// hash tag, 拼出的key与KEYS在同一个slot. 修改 REDIS_KEY_ACCESS_TOKEN、REDIS_KEY_REFRESH_TOKEN 时必须保留 {userId}
+var issueSessionTokensScript = redis.NewScript(`
+local old = redis.call('HGET', KEYS[3], ARGV[2])
+local oldSession
+if old then
+	oldSession = cjson.decode(old)
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+redis.call('SET', KEYS[2], ARGV[1], 'PX', ARGV[4])
+if oldSession then
+	local oldAccessKey = ARGV[6] .. oldSession['access_token']
+	local oldRefreshKey = ARGV[7] .. oldSession['refresh_token']
+	if oldAccessKey ~= KEYS[1] then
+		redis.call('DEL', oldAccessKey)
+	end
+	if oldRefreshKey ~= KEYS[2] then
+		redis.call('PEXPIRE', oldRefreshKey, ARGV[5])
+	end
+end
+redis.call('HSET', KEYS[3], ARGV[2], ARGV[1])
+return old
+`)
+
+// IssueSessionTokens 原子地发放新Token并替换用户在session.Platform平台的Session, 返回被替换的旧Session
+func (rs *redisSessionStore) IssueSessionTokens(ctx context.Context, session *do.SessionInfo) (*do.SessionInfo, error) {
+	sessionDataBytes, err := json.Marshal(session)
 	if err != nil {
+		return nil, err
+	}
+	keys := []string{
+		fmt.Sprintf(enum.REDIS_KEY_ACCESS_TOKEN, session.UserId, session.AccessToken),
+		fmt.Sprintf(enum.REDIS_KEY_REFRESH_TOKEN, session.UserId, session.RefreshToken),
+		fmt.Sprintf(enum.REDIS_KEY_USER_SESSION, session.UserId),
+	}
+	old, err := issueSessionTokensScript.Run(ctx, rs.client, keys, sessionDataBytes, session.Platform,
+		enum.AccessTokenDuration.Milliseconds(), enum.RefreshTokenDuration.Milliseconds(),
+		enum.OldRefreshTokenHoldingDuration.Milliseconds(),
+		fmt.Sprintf(enum.REDIS_KEY_ACCESS_TOKEN, session.UserId, ""),
+		fmt.Sprintf(enum.REDIS_KEY_REFRESH_TOKEN, session.UserId, "")).Text()
+	if err != nil && !errors.Is(err, redis.Nil) {
 		logger.New().Error(ctx, "redis error", "err", err)
-		return err
+		return nil, err
+	}
+	var oldSession *do.SessionInfo
+	if err == nil {
+		oldSession = new(do.SessionInfo)
+		if err = json.Unmarshal([]byte(old), oldSession); err != nil {
+			return nil, err
+		}
 	}
-	return err
+	if legacyKeys() {
+		// 新旧版本混合部署期间新旧key中可能同时有同一平台的Session, 不管新key中有没有旧Session都要清理旧key,
+		// 否则旧key中的Token在过期前仍然有效
+		legacySession, err := rs.replaceLegacySession(ctx, session)
+		if err != nil {
+			return nil, err
+		}
+		if oldSession == nil {
+			oldSession = legacySession
+		}
+	}
+	return oldSession, nil
 }
 
-// DelOldSessionTokens 删除用户旧Session的Token
-func DelOldSessionTokens(ctx context.Context, session *do.SessionInfo) error {
-	//log := logger.New(ctx)
-	oldSession, err := GetUserPlatformSession(ctx, session.UserId, session.Platform)
+// replaceLegacySession 新Session已经写入新key, 清理用户在同一平台升级前的Session, 返回被替换的旧Session
+// 旧key没有hash tag, 集群模式下不在同一个slot, 不能放在发放Token的脚本中
+func (rs *redisSessionStore) replaceLegacySession(ctx context.Context, session *do.SessionInfo) (*do.SessionInfo, error) {
+	legacySessionKey := fmt.Sprintf(enum.REDIS_KEY_LEGACY_USER_SESSION, session.UserId)
+	old, err := rs.client.HGet(ctx, legacySessionKey, session.Platform).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if oldSession == nil {
-		// 没有旧Session
-		return nil
+	oldSession := new(do.SessionInfo)
+	if err = json.Unmarshal([]byte(old), oldSession); err != nil {
+		return nil, err
 	}
-	err = DelAccessToken(ctx, oldSession.AccessToken)
-	if err != nil {
-		return errcode.Wrap("redis error", err)
+	if err = rs.client.Del(ctx, fmt.Sprintf(enum.REDIS_KEY_LEGACY_ACCESS_TOKEN, oldSession.AccessToken)).Err(); err != nil {
+		return nil, err
 	}
-	err = DelayDelRefreshToken(ctx, oldSession.RefreshToken)
-	if err != nil {
-		return errcode.Wrap("redis error", err)
+	legacyRefreshKey := fmt.Sprintf(enum.REDIS_KEY_LEGACY_REFRESH_TOKEN, oldSession.RefreshToken)
+	if err = rs.client.Expire(ctx, legacyRefreshKey, enum.OldRefreshTokenHoldingDuration).Err(); err != nil {
+		return nil, err
 	}
-	return nil
+	if err = rs.client.HDel(ctx, legacySessionKey, session.Platform).Err(); err != nil {
+		return nil, err
+	}
+	return oldSession, nil
 }
 
 // GetUserPlatformSession 获取用户在指定平台中的Session信息
-func GetUserPlatformSession(ctx context.Context, userId int64, platform string) (*do.SessionInfo, error) {
+func (rs *redisSessionStore) GetUserPlatformSession(ctx context.Context, userId int64, platform string) (*do.SessionInfo, error) {
 	redisKey := fmt.Sprintf(enum.REDIS_KEY_USER_SESSION, userId)
-	result, err := Redis().HGet(ctx, redisKey, platform).Result()
+	result, err := rs.client.HGet(ctx, redisKey, platform).Result()
+	if errors.Is(err, redis.Nil) && legacyKeys() {
+		result, err = rs.client.HGet(ctx, fmt.Sprintf(enum.REDIS_KEY_LEGACY_USER_SESSION, userId), platform).Result()
+	}
 	if err != nil && err != redis.Nil {
 		return nil, err
 	}
@@ -81,54 +175,141 @@ func GetUserPlatformSession(ctx context.Context, userId int64, platform string)
 	return session, nil
 }
 
-func setAccessToken(ctx context.Context, session *do.SessionInfo) error {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_ACCESS_TOKEN, session.AccessToken)
-	sessionDataBytes, _ := json.Marshal(session)
-	res, err := Redis().Set(ctx, redisKey, sessionDataBytes, enum.AccessTokenDuration).Result()
-	logger.New().Debug(ctx, "redis debug", "res", res, "err", err)
-	return err
+func (rs *redisSessionStore) DelAccessToken(ctx context.Context, accessToken string) error {
+	redisKey := accessTokenKey(accessToken)
+	if err := rs.client.Del(ctx, redisKey).Err(); err != nil || !legacyKeys() {
+		return err
+	}
+	return rs.client.Del(ctx, fmt.Sprintf(enum.REDIS_KEY_LEGACY_ACCESS_TOKEN, accessToken)).Err()
 }
 
-func setRefreshToken(ctx context.Context, session *do.SessionInfo) error {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_REFRESH_TOKEN, session.RefreshToken)
-	sessionDataBytes, _ := json.Marshal(session)
-	return Redis().Set(ctx, redisKey, sessionDataBytes, enum.RefreshTokenDuration).Err()
+// DelayDelRefreshToken 刷新Token时让旧的RefreshToken 保留一段时间自己过期
+func (rs *redisSessionStore) DelayDelRefreshToken(ctx context.Context, refreshToken string) error {
+	redisKey := refreshTokenKey(refreshToken)
+	if err := rs.client.Expire(ctx, redisKey, enum.OldRefreshTokenHoldingDuration).Err(); err != nil || !legacyKeys() {
+		return err
+	}
+	return rs.client.Expire(ctx, fmt.Sprintf(enum.REDIS_KEY_LEGACY_REFRESH_TOKEN, refreshToken), enum.OldRefreshTokenHoldingDuration).Err()
 }
 
-func DelAccessToken(ctx context.Context, accessToken string) error {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_ACCESS_TOKEN, accessToken)
-	return Redis().Del(ctx, redisKey).Err()
+// DelRefreshToken 直接删除RefreshToken缓存  修改密码、退出登录时使用
+func (rs *redisSessionStore) DelRefreshToken(ctx context.Context, refreshToken string) error {
+	redisKey := refreshTokenKey(refreshToken)
+	if err := rs.client.Del(ctx, redisKey).Err(); err != nil || !legacyKeys() {
+		return err
+	}
+	return rs.client.Del(ctx, fmt.Sprintf(enum.REDIS_KEY_LEGACY_REFRESH_TOKEN, refreshToken)).Err()
 }
 
-// DelayDelRefreshToken 刷新Token时让旧的RefreshToken 保留一段时间自己过期
-func DelayDelRefreshToken(ctx context.Context, refreshToken string) error {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_REFRESH_TOKEN, refreshToken)
-	return Redis().Expire(ctx, redisKey, enum.OldRefreshTokenHoldingDuration).Err()
+// DelUserPlatformSession 删除用户在指定平台的Session以及Session对应的Token, 退出登录时使用
+func (rs *redisSessionStore) DelUserPlatformSession(ctx context.Context, userId int64, platform string) error {
+	session, err := rs.GetUserPlatformSession(ctx, userId, platform)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return nil
+	}
+	if err = rs.delSessionTokens(ctx, session); err != nil {
+		return err
+	}
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_USER_SESSION, userId)
+	if err = rs.client.HDel(ctx, redisKey, platform).Err(); err != nil || !legacyKeys() {
+		return err
+	}
+	return rs.client.HDel(ctx, fmt.Sprintf(enum.REDIS_KEY_LEGACY_USER_SESSION, userId), platform).Err()
 }
 
-// DelRefreshToken 直接删除RefreshToken缓存  修改密码、退出登录时使用
-func DelRefreshToken(ctx context.Context, refreshToken string) error {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_REFRESH_TOKEN, refreshToken)
-	return Redis().Del(ctx, redisKey).Err()
+// DelUserAllSessions 删除用户在所有平台的Session以及Session对应的Token, 修改密码、重置密码时使用
+func (rs *redisSessionStore) DelUserAllSessions(ctx context.Context, userId int64) error {
+	sessions, err := rs.GetUserAllSessions(ctx, userId)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if err = rs.delSessionTokens(ctx, session); err != nil {
+			return err
+		}
+	}
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_USER_SESSION, userId)
+	if err = rs.client.Del(ctx, redisKey).Err(); err != nil || !legacyKeys() {
+		return err
+	}
+	return rs.client.Del(ctx, fmt.Sprintf(enum.REDIS_KEY_LEGACY_USER_SESSION, userId)).Err()
 }
 
-// TODO Delete user's session of specific platform
+// GetUserAllSessions 获取用户在所有平台的Session信息
+func (rs *redisSessionStore) GetUserAllSessions(ctx context.Context, userId int64) ([]*do.SessionInfo, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_USER_SESSION, userId)
+	result, err := rs.client.HGetAll(ctx, redisKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if legacyKeys() {
+		legacy, err := rs.client.HGetAll(ctx, fmt.Sprintf(enum.REDIS_KEY_LEGACY_USER_SESSION, userId)).Result()
+		if err != nil {
+			return nil, err
+		}
+		// 同一个平台以新key中的Session为准
+		for platform, data := range legacy {
+			if _, ok := result[platform]; !ok {
+				result[platform] = data
+			}
+		}
+	}
+	sessions := make([]*do.SessionInfo, 0, len(result))
+	for _, data := range result {
+		session := new(do.SessionInfo)
+		if err = json.Unmarshal([]byte(data), session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
 
-// TODO Delete user's session of all platform
+// delSessionTokens 直接删除Session对应的AccessToken和RefreshToken
+func (rs *redisSessionStore) delSessionTokens(ctx context.Context, session *do.SessionInfo) error {
+	if err := rs.DelAccessToken(ctx, session.AccessToken); err != nil {
+		return errcode.Wrap("redis error", err)
+	}
+	if err := rs.DelRefreshToken(ctx, session.RefreshToken); err != nil {
+		return errcode.Wrap("redis error", err)
+	}
+	return nil
+}
+
+func (rs *redisSessionStore) SetRefreshResult(ctx context.Context, refreshToken string, tokenInfo *do.TokenInfo, ttl time.Duration) error {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_REFRESH_RESULT, refreshToken)
+	data, err := json.Marshal(tokenInfo)
+	if err != nil {
+		return err
+	}
+	return rs.client.Set(ctx, redisKey, data, ttl).Err()
+}
 
-func LockTokenRefresh(ctx context.Context, refreshToken string) (bool, error) {
-	redisLockKey := fmt.Sprintf(enum.REDISKEY_TOKEN_REFRESH_LOCK, refreshToken)
-	return Redis().SetNX(ctx, redisLockKey, "locked", 10*time.Second).Result()
+func (rs *redisSessionStore) GetRefreshResult(ctx context.Context, refreshToken string) (*do.TokenInfo, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_REFRESH_RESULT, refreshToken)
+	result, err := rs.client.Get(ctx, redisKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tokenInfo := new(do.TokenInfo)
+	if err = json.Unmarshal([]byte(result), tokenInfo); err != nil {
+		return nil, err
+	}
+	return tokenInfo, nil
 }
 
-func UnlockTokenRefresh(ctx context.Context, refreshToken string) error {
-	redisLockKey := fmt.Sprintf(enum.REDISKEY_TOKEN_REFRESH_LOCK, refreshToken)
-	return Redis().Del(ctx, redisLockKey).Err()
+func (rs *redisSessionStore) Locker() *lock.Client {
+	return rs.locker
 }
 
-func GetRefreshToken(ctx context.Context, refreshToken string) (*do.SessionInfo, error) {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_REFRESH_TOKEN, refreshToken)
-	result, err := Redis().Get(ctx, redisKey).Result()
+func (rs *redisSessionStore) GetRefreshToken(ctx context.Context, refreshToken string) (*do.SessionInfo, error) {
+	result, err := rs.getWithLegacy(ctx, refreshTokenKey(refreshToken), fmt.Sprintf(enum.REDIS_KEY_LEGACY_REFRESH_TOKEN, refreshToken))
 	if err != nil && err != redis.Nil {
 		return nil, err
 	}
@@ -141,9 +322,25 @@ func GetRefreshToken(ctx context.Context, refreshToken string) (*do.SessionInfo,
 	return session, nil
 }
 
-func GetAccessToken(ctx context.Context, accessToken string) (*do.SessionInfo, error) {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_ACCESS_TOKEN, accessToken)
-	result, err := Redis().Get(ctx, redisKey).Result()
+func (rs *redisSessionStore) GetAccessTokenTTL(ctx context.Context, accessToken string) (time.Duration, error) {
+	ttl, err := rs.client.PTTL(ctx, accessTokenKey(accessToken)).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl == -2 && legacyKeys() {
+		if ttl, err = rs.client.PTTL(ctx, fmt.Sprintf(enum.REDIS_KEY_LEGACY_ACCESS_TOKEN, accessToken)).Result(); err != nil {
+			return 0, err
+		}
+	}
+	// key不存在时返回-2, 没有过期时间时返回-1, 我们设置的Token都有过期时间
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (rs *redisSessionStore) GetAccessToken(ctx context.Context, accessToken string) (*do.SessionInfo, error) {
+	result, err := rs.getWithLegacy(ctx, accessTokenKey(accessToken), fmt.Sprintf(enum.REDIS_KEY_LEGACY_ACCESS_TOKEN, accessToken))
 	if err != nil && err != redis.Nil {
 		return nil, err
 	}
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/logic/do"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestSession(t *testing.T, userId int64, sessionId string) *do.SessionInfo {
+	t.Helper()
+	accessToken, refreshToken, err := util.GenUserAuthToken(userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &do.SessionInfo{UserId: userId, Platform: enum.PlatformApp, SessionId: sessionId,
+		AccessToken: accessToken, RefreshToken: refreshToken}
+}
+
+// TestIssueSessionTokensLegacy 开启 legacy_session_keys 时, 不管新key中有没有旧Session, 发放Token后旧key中的Session都被清理
+func TestIssueSessionTokensLegacy(t *testing.T) {
+	if err := config.Load("../../config/application.dev.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	config.Redis.LegacySessionKeys = true
+	t.Cleanup(func() { config.Redis.LegacySessionKeys = false })
+	const userId = 10086
+
+	tests := []struct {
+		name          string
+		newKeySession bool // 新key中是否已有同一平台的Session
+		wantOldId     string
+	}{
+		{name: "only legacy session", wantOldId: "legacy"},
+		{name: "legacy and new key session", newKeySession: true, wantOldId: "current"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			mr := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() { _ = client.Close() })
+			store := NewRedisSessionStore(client)
+
+			legacy := newTestSession(t, userId, "legacy")
+			legacyData, _ := json.Marshal(legacy)
+			mr.Set(fmt.Sprintf(enum.REDIS_KEY_LEGACY_ACCESS_TOKEN, legacy.AccessToken), string(legacyData))
+			mr.Set(fmt.Sprintf(enum.REDIS_KEY_LEGACY_REFRESH_TOKEN, legacy.RefreshToken), string(legacyData))
+			mr.HSet(fmt.Sprintf(enum.REDIS_KEY_LEGACY_USER_SESSION, userId), legacy.Platform, string(legacyData))
+			if tt.newKeySession {
+				// 直接写入新key, 不经过发放Token时的旧key清理
+				current := newTestSession(t, userId, "current")
+				currentData, _ := json.Marshal(current)
+				mr.HSet(fmt.Sprintf(enum.REDIS_KEY_USER_SESSION, userId), current.Platform, string(currentData))
+			}
+
+			oldSession, err := store.IssueSessionTokens(ctx, newTestSession(t, userId, "new"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if oldSession == nil || oldSession.SessionId != tt.wantOldId {
+				t.Errorf("IssueSessionTokens() old session = %+v, want %s", oldSession, tt.wantOldId)
+			}
+			session, err := store.GetAccessToken(ctx, legacy.AccessToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if session.UserId != 0 {
+				t.Error("legacy access token still valid")
+			}
+			if mr.HGet(fmt.Sprintf(enum.REDIS_KEY_LEGACY_USER_SESSION, userId), legacy.Platform) != "" {
+				t.Error("legacy user session not removed")
+			}
+			if ttl := mr.TTL(fmt.Sprintf(enum.REDIS_KEY_LEGACY_REFRESH_TOKEN, legacy.RefreshToken)); ttl <= 0 || ttl > enum.OldRefreshTokenHoldingDuration {
+				t.Errorf("legacy refresh token ttl = %v, want delayed deletion", ttl)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// 验证码存在Hash中, code字段为验证码, attempts字段为已校验的次数
+const (
+	verifyCodeField     = "code"
+	verifyAttemptsField = "attempts"
+)
+
+// CheckAndDelVerifyCode 的校验结果
+const (
+	VerifyCodeNotFound         = -1 // 验证码不存在或已过期
+	VerifyCodeMismatch         = 0
+	VerifyCodeMatched          = 1
+	VerifyCodeAttemptsExceeded = 2
+)
+
+// 先累加校验次数再比较, 次数超过上限或者比较通过时删除验证码
+// 只在验证码存在时增加校验次数, 避免验证码过期后HINCRBY创建出一个没有过期时间的Key
+var checkVerifyCodeScript = redis.NewScript(`
+local code = redis.call('HGET', KEYS[1], ARGV[1])
+if not code then
+	return -1
+end
+local attempts = redis.call('HINCRBY', KEYS[1], ARGV[2], 1)
+if attempts > tonumber(ARGV[4]) then
+	redis.call('DEL', KEYS[1])
+	return 2
+end
+if code ~= ARGV[3] then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+return 1
+`)
+
+// LockVerifyCodeResend 设置验证码重新发送的冷却期, 返回false表示还在冷却期内
+func LockVerifyCodeResend(ctx context.Context, scene, target string, cooldown time.Duration) (bool, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_VERIFY_CODE_COOLDOWN, scene, target)
+	return Redis().SetNX(ctx, redisKey, "1", cooldown).Result()
+}
+
+// SetVerifyCode 设置验证码, 会覆盖之前发送的验证码并重置校验次数
+func SetVerifyCode(ctx context.Context, scene, target, code string, ttl time.Duration) error {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_VERIFY_CODE, scene, target)
+	pipe := Redis().TxPipeline()
+	pipe.Del(ctx, redisKey)
+	pipe.HSet(ctx, redisKey, verifyCodeField, code, verifyAttemptsField, 0)
+	pipe.Expire(ctx, redisKey, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// CheckAndDelVerifyCode 校验验证码, 校验通过或者次数超限时删除验证码
+func CheckAndDelVerifyCode(ctx context.Context, scene, target, code string, maxAttempts int64) (int64, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_VERIFY_CODE, scene, target)
+	return checkVerifyCodeScript.Run(ctx, Redis(), []string{redisKey},
+		verifyCodeField, verifyAttemptsField, code, maxAttempts).Int64()
+}
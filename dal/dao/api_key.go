@@ -0,0 +1,64 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"github.com/ljinf/user_auth/dal/model"
+	"gorm.io/gorm"
+)
+
+type ApiKeyDao struct {
+	ctx context.Context
+}
+
+func NewApiKeyDao(ctx context.Context) *ApiKeyDao {
+	return &ApiKeyDao{ctx: ctx}
+}
+
+func (ad *ApiKeyDao) CreateApiKey(apiKey *model.UserApiKey) error {
+	return DBMaster().WithContext(ad.ctx).Create(apiKey).Error
+}
+
+// FindApiKeyByHash 通过Key的哈希查询API Key, 不存在时返回nil
+func (ad *ApiKeyDao) FindApiKeyByHash(keyHash string) (*model.UserApiKey, error) {
+	return ad.findApiKey("key_hash = ?", keyHash)
+}
+
+// FindUserApiKey 查询用户的某个API Key, 不存在时返回nil
+func (ad *ApiKeyDao) FindUserApiKey(userId, keyId int64) (*model.UserApiKey, error) {
+	return ad.findApiKey("id = ? AND user_id = ?", keyId, userId)
+}
+
+func (ad *ApiKeyDao) ListUserApiKeys(userId int64) ([]*model.UserApiKey, error) {
+	apiKeys := make([]*model.UserApiKey, 0)
+	err := DB().WithContext(ad.ctx).Where("user_id = ?", userId).Order("id DESC").Find(&apiKeys).Error
+	return apiKeys, err
+}
+
+func (ad *ApiKeyDao) CountUserApiKeys(userId int64) (int64, error) {
+	var count int64
+	err := DBMaster().WithContext(ad.ctx).Model(&model.UserApiKey{}).Where("user_id = ?", userId).Count(&count).Error
+	return count, err
+}
+
+// UpdateApiKeyHash 轮换API Key时更新Key的前缀和哈希
+func (ad *ApiKeyDao) UpdateApiKeyHash(keyId int64, keyPrefix, keyHash string) error {
+	return DBMaster().WithContext(ad.ctx).Model(&model.UserApiKey{}).Where("id = ?", keyId).
+		Updates(map[string]interface{}{"key_prefix": keyPrefix, "key_hash": keyHash}).Error
+}
+
+func (ad *ApiKeyDao) DeleteApiKey(keyId int64) error {
+	return DBMaster().WithContext(ad.ctx).Where("id = ?", keyId).Delete(&model.UserApiKey{}).Error
+}
+
+func (ad *ApiKeyDao) findApiKey(query string, args ...interface{}) (*model.UserApiKey, error) {
+	apiKey := new(model.UserApiKey)
+	err := DBMaster().WithContext(ad.ctx).Where(query, args...).First(apiKey).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return apiKey, nil
+}
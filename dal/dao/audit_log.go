@@ -0,0 +1,18 @@
+package dao
+
+import (
+	"context"
+	"github.com/ljinf/user_auth/dal/model"
+)
+
+type AuditLogDao struct {
+	ctx context.Context
+}
+
+func NewAuditLogDao(ctx context.Context) *AuditLogDao {
+	return &AuditLogDao{ctx: ctx}
+}
+
+func (ad *AuditLogDao) CreateAuditLog(auditLog *model.UserAuditLog) error {
+	return DBMaster().WithContext(ad.ctx).Create(auditLog).Error
+}
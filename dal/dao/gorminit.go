@@ -1,6 +1,9 @@
 package dao
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/ljinf/user_auth/config"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -19,13 +22,52 @@ func DBMaster() *gorm.DB {
 	return _DbMaster
 }
 
-func init() {
-	//logger.New(context.TODO()).Info("database info", "db", config.Database)
-	_DbMaster = initDB(config.Database.Master)
-	_DbSlave = initDB(config.Database.Slave)
+// Close 关闭主库和从库的连接池, 服务退出时调用
+func Close() error {
+	var errs []error
+	dbs := []*gorm.DB{_DbMaster}
+	if _DbSlave != _DbMaster {
+		dbs = append(dbs, _DbSlave)
+	}
+	for _, db := range dbs {
+		if db == nil {
+			continue
+		}
+		sqlDb, err := db.DB()
+		if err == nil {
+			err = sqlDb.Close()
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Init 按配置连接主库和从库, 需要在 config.Load 之后调用
+func Init(ctx context.Context) error {
+	master, err := initDB(ctx, config.Database.Master)
+	if err != nil {
+		return fmt.Errorf("database master: %w", err)
+	}
+	slave, err := initDB(ctx, config.Database.Slave)
+	if err != nil {
+		if sqlDb, dbErr := master.DB(); dbErr == nil {
+			sqlDb.Close()
+		}
+		return fmt.Errorf("database slave: %w", err)
+	}
+	SetDB(master, slave)
+	return nil
+}
+
+// SetDB 设置主库和从库实例, 测试中可以传入内存数据库, 没有读写分离时两者传入同一个实例
+func SetDB(master, slave *gorm.DB) {
+	_DbMaster = master
+	_DbSlave = slave
 }
 
-func initDB(option config.DbConnectOption) *gorm.DB {
+func initDB(ctx context.Context, option config.DbConnectOption) (*gorm.DB, error) {
 	db, err := gorm.Open(
 		mysql.Open(option.DSN),
 		&gorm.Config{
@@ -33,14 +75,18 @@ func initDB(option config.DbConnectOption) *gorm.DB {
 		},
 	)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	sqlDb, err := db.DB()
+	if err != nil {
+		return nil, err
 	}
-	sqlDb, _ := db.DB()
 	sqlDb.SetMaxOpenConns(option.MaxOpenConn)
 	sqlDb.SetMaxIdleConns(option.MaxIdleConn)
 	sqlDb.SetConnMaxLifetime(option.MaxLifeTime)
-	if err = sqlDb.Ping(); err != nil {
-		panic(err)
+	if err = sqlDb.PingContext(ctx); err != nil {
+		sqlDb.Close()
+		return nil, err
 	}
-	return db
+	return db, nil
 }
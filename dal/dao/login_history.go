@@ -0,0 +1,53 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/dal/model"
+	"gorm.io/gorm"
+)
+
+type LoginHistoryDao struct {
+	ctx context.Context
+}
+
+func NewLoginHistoryDao(ctx context.Context) *LoginHistoryDao {
+	return &LoginHistoryDao{ctx: ctx}
+}
+
+func (ld *LoginHistoryDao) CreateLoginHistory(history *model.UserLoginHistory) error {
+	return DBMaster().WithContext(ld.ctx).Create(history).Error
+}
+
+// GetLastSuccessLogin 获取用户最近一次成功登录的记录, 没有记录时返回nil
+func (ld *LoginHistoryDao) GetLastSuccessLogin(userId int64) (*model.UserLoginHistory, error) {
+	history := new(model.UserLoginHistory)
+	err := DB().WithContext(ld.ctx).Where("user_id = ? AND result = ?", userId, enum.LoginResultSuccess).
+		Order("id DESC").First(history).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// HasSuccessLoginFromCountry 用户是否曾经在该国家成功登录过
+func (ld *LoginHistoryDao) HasSuccessLoginFromCountry(userId int64, countryCode string) (bool, error) {
+	var count int64
+	err := DB().WithContext(ld.ctx).Model(&model.UserLoginHistory{}).
+		Where("user_id = ? AND result = ? AND country_code = ?", userId, enum.LoginResultSuccess, countryCode).
+		Limit(1).Count(&count).Error
+	return count > 0, err
+}
+
+// HasSuccessLoginWithDevice 用户是否曾经使用该设备成功登录过
+func (ld *LoginHistoryDao) HasSuccessLoginWithDevice(userId int64, deviceId string) (bool, error) {
+	var count int64
+	err := DB().WithContext(ld.ctx).Model(&model.UserLoginHistory{}).
+		Where("user_id = ? AND result = ? AND device_id = ?", userId, enum.LoginResultSuccess, deviceId).
+		Limit(1).Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,26 @@
+package dao
+
+import (
+	"context"
+	"github.com/ljinf/user_auth/dal/model"
+)
+
+type PasswordHistoryDao struct {
+	ctx context.Context
+}
+
+func NewPasswordHistoryDao(ctx context.Context) *PasswordHistoryDao {
+	return &PasswordHistoryDao{ctx: ctx}
+}
+
+func (pd *PasswordHistoryDao) CreatePasswordHistory(history *model.UserPasswordHistory) error {
+	return DBMaster().WithContext(pd.ctx).Create(history).Error
+}
+
+// GetRecentPasswordHistories 获取用户最近使用过的limit个密码
+func (pd *PasswordHistoryDao) GetRecentPasswordHistories(userId int64, limit int) ([]*model.UserPasswordHistory, error) {
+	histories := make([]*model.UserPasswordHistory, 0, limit)
+	err := DB().WithContext(pd.ctx).Where("user_id = ?", userId).
+		Order("id DESC").Limit(limit).Find(&histories).Error
+	return histories, err
+}
@@ -0,0 +1,62 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"github.com/ljinf/user_auth/dal/model"
+	"gorm.io/gorm"
+)
+
+type UserDao struct {
+	ctx context.Context
+}
+
+func NewUserDao(ctx context.Context) *UserDao {
+	return &UserDao{ctx: ctx}
+}
+
+// FindUserById 查询用户, 用户不存在时返回nil
+func (ud *UserDao) FindUserById(userId int64) (*model.User, error) {
+	return ud.findUser("id = ?", userId)
+}
+
+// FindUserByPhone 通过手机号查询用户, 用户不存在时返回nil
+func (ud *UserDao) FindUserByPhone(phone string) (*model.User, error) {
+	return ud.findUser("phone = ?", phone)
+}
+
+// FindUserByEmail 通过邮箱查询用户, 用户不存在时返回nil
+func (ud *UserDao) FindUserByEmail(email string) (*model.User, error) {
+	return ud.findUser("email = ?", email)
+}
+
+// FindUsersByIds 批量查询用户, 不存在的用户不会出现在结果中
+func (ud *UserDao) FindUsersByIds(userIds []int64) ([]*model.User, error) {
+	users := make([]*model.User, 0, len(userIds))
+	err := DB().WithContext(ud.ctx).Where("id IN ?", userIds).Find(&users).Error
+	return users, err
+}
+
+// UpdateUserVerified 更新用户的认证状态
+func (ud *UserDao) UpdateUserVerified(userId int64, verified int) error {
+	return DBMaster().WithContext(ud.ctx).Model(&model.User{}).Where("id = ?", userId).
+		Update("verified", verified).Error
+}
+
+// UpdateUserPassword 更新用户的密码哈希
+func (ud *UserDao) UpdateUserPassword(userId int64, passwordHash string) error {
+	return DBMaster().WithContext(ud.ctx).Model(&model.User{}).Where("id = ?", userId).
+		Update("password", passwordHash).Error
+}
+
+func (ud *UserDao) findUser(query string, args ...interface{}) (*model.User, error) {
+	user := new(model.User)
+	err := DB().WithContext(ud.ctx).Where(query, args...).First(user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"gorm.io/plugin/soft_delete"
+	"time"
+)
+
+type User struct {
+	Id        int64                 `gorm:"column:id;primary_key" json:"id"`                      //自增ID
+	Nickname  string                `gorm:"column:nickname;type:varchar(32)" json:"nickname"`     //昵称
+	LoginName string                `gorm:"column:login_name;type:varchar(64)" json:"login_name"` //登录名
+	Password  string                `gorm:"column:password;type:varchar(128)" json:"-"`           //密码哈希
+	Phone     string                `gorm:"column:phone;type:varchar(20)" json:"phone"`           //手机号
+	Email     string                `gorm:"column:email;type:varchar(128)" json:"email"`          //邮箱
+	Verified  int                   `gorm:"column:verified;default:0" json:"verified"`            //0-未认证，1-已认证
+	Avatar    string                `gorm:"column:avatar;type:varchar(255)" json:"avatar"`        //头像
+	Slogan    string                `gorm:"column:slogan;type:varchar(255)" json:"slogan"`        //签名
+	IsBlocked int                   `gorm:"column:is_blocked;default:0" json:"is_blocked"`        //0-正常，1-已封禁
+	IsDel     soft_delete.DeletedAt `gorm:"softDelete:flag"`
+	CreatedAt time.Time             `gorm:"column:created_at" json:"created_at"` //创建时间
+	UpdatedAt time.Time             `gorm:"column:updated_at" json:"updated_at"` //更新时间
+}
+
+func (User) TableName() string {
+	return "users"
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"gorm.io/plugin/soft_delete"
+	"time"
+)
+
+// UserApiKey 用户为脚本等机器客户端创建的API Key, 只保存Key的哈希, 吊销时软删除
+type UserApiKey struct {
+	Id        int64                 `gorm:"column:id;primary_key" json:"id"`                       //自增ID
+	UserId    int64                 `gorm:"column:user_id" json:"user_id"`                         //所属用户ID
+	Name      string                `gorm:"column:name;type:varchar(64)" json:"name"`              //名称
+	KeyPrefix string                `gorm:"column:key_prefix;type:varchar(16)" json:"key_prefix"`  //Key的前缀, 用于展示
+	KeyHash   string                `gorm:"column:key_hash;type:varchar(64);uniqueIndex" json:"-"` //Key的SHA256哈希
+	Scope     string                `gorm:"column:scope;type:varchar(255)" json:"scope"`           //允许的scope, 空格分隔
+	ExpiredAt *time.Time            `gorm:"column:expired_at" json:"expired_at"`                   //过期时间, 为空时永不过期
+	CreatedBy int64                 `gorm:"column:created_by" json:"created_by"`                   //创建人, 管理员代用户创建时与UserId不同
+	IsDel     soft_delete.DeletedAt `gorm:"softDelete:flag"`
+	CreatedAt time.Time             `gorm:"column:created_at" json:"created_at"` //创建时间
+	UpdatedAt time.Time             `gorm:"column:updated_at" json:"updated_at"` //更新时间
+}
+
+func (UserApiKey) TableName() string {
+	return "user_api_keys"
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// UserAuditLog 用户安全相关操作的审计日志
+type UserAuditLog struct {
+	Id        int64     `gorm:"column:id;primary_key" json:"id"`                //自增ID
+	UserId    int64     `gorm:"column:user_id" json:"user_id"`                  //用户ID
+	Event     string    `gorm:"column:event;type:varchar(32)" json:"event"`     //事件类型
+	Ip        string    `gorm:"column:ip;type:varchar(64)" json:"ip"`           //操作IP
+	Detail    string    `gorm:"column:detail;type:varchar(1024)" json:"detail"` //事件详情, JSON格式
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`            //创建时间
+}
+
+func (UserAuditLog) TableName() string {
+	return "user_audit_logs"
+}
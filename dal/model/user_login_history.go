@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// UserLoginHistory 用户登录历史, 风控引擎根据历史记录判断本次登录是否异常
+type UserLoginHistory struct {
+	Id          int64     `gorm:"column:id;primary_key" json:"id"`                           //自增ID
+	UserId      int64     `gorm:"column:user_id" json:"user_id"`                             //用户ID
+	Platform    string    `gorm:"column:platform;type:varchar(16)" json:"platform"`          //登录平台 app,h5,pc
+	Ip          string    `gorm:"column:ip;type:varchar(64)" json:"ip"`                      //登录IP
+	CountryCode string    `gorm:"column:country_code;type:varchar(8)" json:"country_code"`   //IP所属国家
+	City        string    `gorm:"column:city;type:varchar(64)" json:"city"`                  //IP所属城市
+	Latitude    float64   `gorm:"column:latitude" json:"latitude"`                           //纬度
+	Longitude   float64   `gorm:"column:longitude" json:"longitude"`                         //经度
+	DeviceId    string    `gorm:"column:device_id;type:varchar(64)" json:"device_id"`        //设备标识
+	UserAgent   string    `gorm:"column:user_agent;type:varchar(255)" json:"user_agent"`     //UA
+	Result      int8      `gorm:"column:result" json:"result"`                               //1-成功，2-失败，3-需要二次验证，4-拒绝
+	RiskScore   int       `gorm:"column:risk_score" json:"risk_score"`                       //风险评分
+	RiskReasons string    `gorm:"column:risk_reasons;type:varchar(255)" json:"risk_reasons"` //命中的风险项, 逗号分隔
+	CreatedAt   time.Time `gorm:"column:created_at" json:"created_at"`                       //创建时间
+}
+
+func (UserLoginHistory) TableName() string {
+	return "user_login_histories"
+}
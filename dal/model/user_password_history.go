@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// UserPasswordHistory 用户使用过的密码, 用于防止重复使用旧密码
+type UserPasswordHistory struct {
+	Id        int64     `gorm:"column:id;primary_key" json:"id"`            //自增ID
+	UserId    int64     `gorm:"column:user_id" json:"user_id"`              //用户ID
+	Password  string    `gorm:"column:password;type:varchar(128)" json:"-"` //密码哈希
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`        //创建时间
+}
+
+func (UserPasswordHistory) TableName() string {
+	return "user_password_histories"
+}
@@ -0,0 +1,60 @@
+package library
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// BreachedPasswordChecker 检查密码是否出现在已泄露的密码库中
+// 采用 k-anonymity 的方式: 只用密码 SHA-1 的前5位定位范围, 再在范围内比对剩余的35位
+// 以后需要改用 haveibeenpwned 的在线 range 接口时, 实现这个接口即可
+type BreachedPasswordChecker interface {
+	// BreachedCount 返回密码在泄露密码库中出现的次数, 没有出现过返回0
+	BreachedCount(ctx context.Context, password string) (int, error)
+}
+
+// NewBreachedPasswordChecker 使用本地目录中的泄露密码库
+func NewBreachedPasswordChecker(corpusDir string) BreachedPasswordChecker {
+	return &FileBreachedPasswordChecker{dir: corpusDir}
+}
+
+// FileBreachedPasswordChecker 本地泄露密码库, 按 SHA-1 前5位分文件存放: <dir>/<前5位>.txt
+// 文件的每一行为 剩余35位:出现次数, 与 haveibeenpwned range 接口的返回格式相同
+type FileBreachedPasswordChecker struct {
+	dir string
+}
+
+func (fc *FileBreachedPasswordChecker) BreachedCount(ctx context.Context, password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(fc.dir, prefix+".txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		// 这个范围内没有泄露的密码
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		hashSuffix, count, found := strings.Cut(line, ":")
+		if !found || !strings.EqualFold(hashSuffix, suffix) {
+			continue
+		}
+		return strconv.Atoi(count)
+	}
+	return 0, scanner.Err()
+}
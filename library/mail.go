@@ -0,0 +1,166 @@
+package library
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/config"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Mail 要发送的邮件, Body为HTML格式
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// MailSender 邮件发送接口, 通过 NewMailSender 按配置获取具体实现
+type MailSender interface {
+	Send(ctx context.Context, m *Mail) error
+}
+
+// NewMailSender 根据配置中的 mail.provider 返回对应的邮件发送实现
+// 不真正发送的 capture 需要明确配置, 没有配置或者配置了不支持的实现时返回错误
+func NewMailSender() (MailSender, error) {
+	if config.Mail == nil {
+		return nil, fmt.Errorf("mail is not configured")
+	}
+	switch config.Mail.Provider {
+	case "smtp":
+		return &SmtpMailSender{}, nil
+	case "capture":
+		return captureSender, nil
+	default:
+		return nil, fmt.Errorf("unknown mail provider: %q", config.Mail.Provider)
+	}
+}
+
+// SmtpMailSender 通过配置的SMTP服务器发送邮件
+type SmtpMailSender struct{}
+
+func (s *SmtpMailSender) Send(ctx context.Context, m *Mail) error {
+	mailConf := config.Mail
+	addr := net.JoinHostPort(mailConf.Host, strconv.Itoa(mailConf.Port))
+	msg := buildMailMessage(m)
+
+	var (
+		client *smtp.Client
+		err    error
+	)
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if mailConf.SSL {
+		conn, dialErr := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: mailConf.Host})
+		if dialErr != nil {
+			return dialErr
+		}
+		client, err = smtp.NewClient(conn, mailConf.Host)
+	} else {
+		conn, dialErr := dialer.DialContext(ctx, "tcp", addr)
+		if dialErr != nil {
+			return dialErr
+		}
+		client, err = smtp.NewClient(conn, mailConf.Host)
+		if err == nil {
+			if ok, _ := client.Extension("STARTTLS"); ok {
+				err = client.StartTLS(&tls.Config{ServerName: mailConf.Host})
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if mailConf.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", mailConf.Username, mailConf.Password, mailConf.Host)); err != nil {
+			return err
+		}
+	}
+	if err = client.Mail(mailConf.From); err != nil {
+		return err
+	}
+	if err = client.Rcpt(m.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	logger.New().Info(ctx, "mail sent", "to", m.To, "subject", m.Subject)
+	return client.Quit()
+}
+
+func buildMailMessage(m *Mail) []byte {
+	from := mail.Address{Name: config.Mail.FromName, Address: config.Mail.From}
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(buf, "To: %s\r\n", m.To)
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", m.Subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(m.Body))
+	// 按RFC 2045 的要求每行不超过76个字符
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	return buf.Bytes()
+}
+
+const maxCapturedMails = 100
+
+var captureSender = &CaptureMailSender{}
+
+// CaptureMailSender 只把邮件保存在内存中并记录日志, 不真正发送, 开发和测试环境使用
+// 测试中可以通过 CapturedMails 拿到发出的邮件, 从中读取验证码等内容
+type CaptureMailSender struct {
+	mu    sync.Mutex
+	mails []*Mail
+}
+
+func (s *CaptureMailSender) Send(ctx context.Context, m *Mail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mails = append(s.mails, m)
+	if len(s.mails) > maxCapturedMails {
+		// 开发环境长时间运行时只保留最近的邮件
+		s.mails = s.mails[len(s.mails)-maxCapturedMails:]
+	}
+	logger.New().Info(ctx, "mail captured", "to", m.To, "subject", m.Subject, "body", m.Body)
+	return nil
+}
+
+// CapturedMails 返回 CaptureMailSender 保存的所有邮件
+func CapturedMails() []*Mail {
+	captureSender.mu.Lock()
+	defer captureSender.mu.Unlock()
+	mails := make([]*Mail, len(captureSender.mails))
+	copy(mails, captureSender.mails)
+	return mails
+}
+
+// ResetCapturedMails 清空 CaptureMailSender 保存的邮件
+func ResetCapturedMails() {
+	captureSender.mu.Lock()
+	defer captureSender.mu.Unlock()
+	captureSender.mails = nil
+}
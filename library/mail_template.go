@@ -0,0 +1,86 @@
+package library
+
+import (
+	"bytes"
+	"embed"
+	"github.com/ljinf/user_auth/config"
+	"html/template"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// 邮件模板按语言存放在 mailtemplate/<locale>/<name>.tmpl 中
+// 每个模板文件需要定义 subject 和 body 两个模板, 新增语言时增加一个目录即可
+
+//go:embed mailtemplate
+var mailTemplateFS embed.FS
+
+var (
+	mailTemplates     map[string]*template.Template // key: locale/name
+	mailLocales       []string
+	mailTemplatesOnce sync.Once
+)
+
+func loadMailTemplates() {
+	mailTemplates = make(map[string]*template.Template)
+	entries, _ := fs.ReadDir(mailTemplateFS, "mailtemplate")
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		locale := entry.Name()
+		mailLocales = append(mailLocales, locale)
+		files, _ := fs.Glob(mailTemplateFS, path.Join("mailtemplate", locale, "*.tmpl"))
+		for _, file := range files {
+			name := strings.TrimSuffix(path.Base(file), ".tmpl")
+			// 模板是随代码一起发布的, 解析失败直接panic
+			mailTemplates[locale+"/"+name] = template.Must(template.ParseFS(mailTemplateFS, file))
+		}
+	}
+}
+
+// MatchMailLocale 根据客户端的 Accept-Language 选择邮件模板的语言
+// 先完全匹配再按主语言匹配(en 匹配 en-US), 都没有时使用配置的默认语言
+func MatchMailLocale(acceptLanguage string) string {
+	mailTemplatesOnce.Do(loadMailTemplates)
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag := strings.TrimSpace(strings.Split(part, ";")[0])
+		if tag == "" {
+			continue
+		}
+		for _, locale := range mailLocales {
+			if strings.EqualFold(locale, tag) {
+				return locale
+			}
+		}
+		lang := strings.Split(tag, "-")[0]
+		for _, locale := range mailLocales {
+			if strings.EqualFold(strings.Split(locale, "-")[0], lang) {
+				return locale
+			}
+		}
+	}
+	return config.Mail.DefaultLocale
+}
+
+// RenderMail 使用指定语言的模板渲染邮件, 该语言没有这个模板时使用默认语言的模板
+func RenderMail(name, locale, to string, data interface{}) (*Mail, error) {
+	mailTemplatesOnce.Do(loadMailTemplates)
+	tpl, ok := mailTemplates[locale+"/"+name]
+	if !ok {
+		tpl, ok = mailTemplates[config.Mail.DefaultLocale+"/"+name]
+	}
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	subject, body := new(bytes.Buffer), new(bytes.Buffer)
+	if err := tpl.ExecuteTemplate(subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := tpl.ExecuteTemplate(body, "body", data); err != nil {
+		return nil, err
+	}
+	return &Mail{To: to, Subject: subject.String(), Body: body.String()}, nil
+}
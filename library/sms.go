@@ -0,0 +1,42 @@
+package library
+
+import (
+	"context"
+	"fmt"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/config"
+)
+
+// SmsSender 短信发送接口, 对接新的短信服务商时实现这个接口, 并在 NewSmsSender 中按配置返回即可
+type SmsSender interface {
+	Send(ctx context.Context, phone string, content string) error
+}
+
+// NewSmsSender 根据配置中的 sms.provider 返回对应的短信发送实现
+// 只记日志的 log 需要明确配置, 没有配置或者配置了不支持的服务商时返回错误, 避免生产环境的短信被悄悄丢弃
+// log 会把验证码写进日志, 生产环境不允许使用
+func NewSmsSender() (SmsSender, error) {
+	if config.Sms == nil {
+		return nil, fmt.Errorf("sms is not configured")
+	}
+	switch config.Sms.Provider {
+	case "":
+		return nil, fmt.Errorf("sms provider is not configured")
+	case "log":
+		if config.App.Env == enum.ModeProd {
+			return nil, fmt.Errorf("sms provider log writes verify codes into logs, not allowed in %s", enum.ModeProd)
+		}
+		return &LogSmsSender{}, nil
+	default:
+		return nil, fmt.Errorf("unknown sms provider: %q", config.Sms.Provider)
+	}
+}
+
+// LogSmsSender 只把短信内容记到日志里, 不真正发送短信, 开发和测试环境使用
+type LogSmsSender struct{}
+
+func (s *LogSmsSender) Send(ctx context.Context, phone string, content string) error {
+	logger.New().Info(ctx, "sms send", "phone", phone, "content", content)
+	return nil
+}
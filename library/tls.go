@@ -0,0 +1,97 @@
+package library
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/ljinf/user_auth/config"
+	"os"
+)
+
+// NewServerTLSConfig 按配置创建面向外部的HTTP服务和gRPC服务的TLS配置, 未开启TLS时返回nil
+// 浏览器没有客户端证书, 所以面向外部的服务最多只校验调用方主动提供的客户端证书
+func NewServerTLSConfig() (*tls.Config, error) {
+	if config.ServerTLS == nil || !config.ServerTLS.Enabled {
+		return nil, nil
+	}
+	switch config.ServerTLS.ClientAuth {
+	case "", "none":
+		return newServerTLSConfig(tls.NoClientCert)
+	case "request":
+		return newServerTLSConfig(tls.VerifyClientCertIfGiven)
+	case "require":
+		return nil, errors.New("client_auth require would reject browsers, configure internal_addr for the mTLS listener instead")
+	default:
+		return nil, fmt.Errorf("unknown client_auth: %s", config.ServerTLS.ClientAuth)
+	}
+}
+
+// NewInternalTLSConfig 按配置创建只提供给内部服务的HTTP服务的TLS配置, 要求调用方必须提供客户端证书
+// 没有开启TLS或者没有配置 internal_addr 时返回nil
+func NewInternalTLSConfig() (*tls.Config, error) {
+	if config.ServerTLS == nil || !config.ServerTLS.Enabled || config.ServerTLS.InternalAddr == "" {
+		return nil, nil
+	}
+	return newServerTLSConfig(tls.RequireAndVerifyClientCert)
+}
+
+func newServerTLSConfig(clientAuth tls.ClientAuthType) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(config.ServerTLS.CertFile, config.ServerTLS.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		ClientAuth:   clientAuth,
+	}
+	if clientAuth == tls.NoClientCert {
+		return tlsConfig, nil
+	}
+	tlsConfig.ClientCAs, err = LoadCertPool(config.ServerTLS.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	return tlsConfig, nil
+}
+
+// CertPrincipal 根据已通过CA校验的客户端证书找到对应的内部服务, 没有客户端证书或者证书不属于任何服务时返回空
+func CertPrincipal(state *tls.ConnectionState) string {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	cert := state.VerifiedChains[0][0]
+	names := append([]string{}, cert.DNSNames...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	for _, principal := range config.ServerTLS.Principals {
+		for _, cn := range principal.CommonNames {
+			if cn == cert.Subject.CommonName {
+				return principal.Service
+			}
+		}
+		for _, san := range principal.Sans {
+			for _, name := range names {
+				if san == name {
+					return principal.Service
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// LoadCertPool 从PEM文件中加载CA证书, 文件中可以包含多个证书
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificate found in " + caFile)
+	}
+	return pool, nil
+}
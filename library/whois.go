@@ -39,10 +39,15 @@ type WhoisIpDetail struct {
 }
 
 func (whois *WhoisLib) GetHostIpDetail() (*WhoisIpDetail, error) {
+	return whois.GetIpDetail("")
+}
+
+// GetIpDetail 查询指定IP的归属地信息, ip为空时查询的是本机的出口IP
+func (whois *WhoisLib) GetIpDetail(ip string) (*WhoisIpDetail, error) {
 	log := logger.New()
 
 	httpStatusCode, respBody, err := httptool.Get(
-		whois.ctx, "https://ipwho.is",
+		whois.ctx, "https://ipwho.is/"+ip,
 		httptool.WithHeaders(map[string]string{
 			"User-Agent": "abc/123456",
 		}),
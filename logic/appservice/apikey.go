@@ -0,0 +1,70 @@
+package appservice
+
+import (
+	"context"
+	"github.com/ljinf/user_auth/api/reply"
+	"github.com/ljinf/user_auth/api/request"
+	"github.com/ljinf/user_auth/logic/do"
+	"github.com/ljinf/user_auth/logic/domainservice"
+)
+
+// ApiKeyAppSvc 用户管理自己的API Key, 管理员管理其他用户的API Key
+// operatorId 为当前登录的用户, userId 为API Key所属的用户
+type ApiKeyAppSvc struct {
+	ctx             context.Context
+	apiKeyDomainSvc *domainservice.ApiKeyDomainSvc
+}
+
+func NewApiKeyAppSvc(ctx context.Context) *ApiKeyAppSvc {
+	return &ApiKeyAppSvc{
+		ctx:             ctx,
+		apiKeyDomainSvc: domainservice.NewApiKeyDomainSvc(ctx),
+	}
+}
+
+func (as *ApiKeyAppSvc) CreateApiKey(operatorId, userId int64, req *request.ApiKeyCreate, client *request.ClientInfo) (*reply.ApiKeyReply, error) {
+	apiKey, key, err := as.apiKeyDomainSvc.Create(operatorId, userId, req.Name, req.Scope, req.ExpiredAt, client.Ip)
+	if err != nil {
+		return nil, err
+	}
+	apiKeyReply := toApiKeyReply(apiKey)
+	apiKeyReply.Key = key
+	return apiKeyReply, nil
+}
+
+func (as *ApiKeyAppSvc) ListApiKeys(userId int64) ([]*reply.ApiKeyReply, error) {
+	apiKeys, err := as.apiKeyDomainSvc.List(userId)
+	if err != nil {
+		return nil, err
+	}
+	replies := make([]*reply.ApiKeyReply, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		replies = append(replies, toApiKeyReply(apiKey))
+	}
+	return replies, nil
+}
+
+func (as *ApiKeyAppSvc) RotateApiKey(operatorId, userId, keyId int64, client *request.ClientInfo) (*reply.ApiKeyReply, error) {
+	apiKey, key, err := as.apiKeyDomainSvc.Rotate(operatorId, userId, keyId, client.Ip)
+	if err != nil {
+		return nil, err
+	}
+	apiKeyReply := toApiKeyReply(apiKey)
+	apiKeyReply.Key = key
+	return apiKeyReply, nil
+}
+
+func (as *ApiKeyAppSvc) RevokeApiKey(operatorId, userId, keyId int64, client *request.ClientInfo) error {
+	return as.apiKeyDomainSvc.Revoke(operatorId, userId, keyId, client.Ip)
+}
+
+func toApiKeyReply(apiKey *do.ApiKey) *reply.ApiKeyReply {
+	return &reply.ApiKeyReply{
+		Id:        apiKey.Id,
+		Name:      apiKey.Name,
+		KeyPrefix: apiKey.KeyPrefix,
+		Scope:     apiKey.Scope,
+		ExpiredAt: apiKey.ExpiredAt,
+		CreatedAt: apiKey.CreatedAt,
+	}
+}
@@ -0,0 +1,99 @@
+package appservice
+
+import (
+	"context"
+	"github.com/ljinf/user_auth/api/reply"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/logic/domainservice"
+)
+
+// maxBatchUsers 批量查询用户信息时一次最多查询的用户数
+const maxBatchUsers = 100
+
+// AuthAppSvc 提供给内部服务的认证接口, gRPC AuthService 使用
+type AuthAppSvc struct {
+	ctx           context.Context
+	userDomainSvc *domainservice.UserDomainSvc
+}
+
+func NewAuthAppSvc(ctx context.Context) *AuthAppSvc {
+	return &AuthAppSvc{
+		ctx:           ctx,
+		userDomainSvc: domainservice.NewUserDomainSvc(ctx),
+	}
+}
+
+func (as *AuthAppSvc) VerifyAccessToken(accessToken string) (*reply.TokenVerifyReply, error) {
+	if len(accessToken) != 40 { // 我们生成的token长度为40
+		return &reply.TokenVerifyReply{Approved: false}, nil
+	}
+	tokenVerify, err := as.userDomainSvc.VerifyAccessToken(accessToken)
+	if err != nil {
+		return nil, errcode.Wrap("VerifyAccessTokenErr", err)
+	}
+	return &reply.TokenVerifyReply{
+		Approved:  tokenVerify.Approved,
+		UserId:    tokenVerify.UserId,
+		SessionId: tokenVerify.SessionId,
+		Platform:  tokenVerify.Platform,
+		Scope:     tokenVerify.Scope,
+	}, nil
+}
+
+func (as *AuthAppSvc) GetUserBaseInfo(userId int64) (*reply.UserBaseInfoReply, error) {
+	return NewUserAppSvc(as.ctx).GetUserBaseInfo(userId)
+}
+
+// BatchGetUserBaseInfo 批量查询用户的基本信息, 不存在的用户不会出现在结果中
+func (as *AuthAppSvc) BatchGetUserBaseInfo(userIds []int64) ([]*reply.UserBaseInfoReply, error) {
+	if len(userIds) == 0 || len(userIds) > maxBatchUsers {
+		return nil, errcode.ErrParams
+	}
+	userInfos, err := as.userDomainSvc.GetUserBaseInfos(userIds)
+	if err != nil {
+		return nil, err
+	}
+	replies := make([]*reply.UserBaseInfoReply, 0, len(userInfos))
+	for _, userInfo := range userInfos {
+		userInfoReply := new(reply.UserBaseInfoReply)
+		util.CopyProperties(userInfoReply, userInfo)
+		userInfoReply.Id = userInfo.ID
+		replies = append(replies, userInfoReply)
+	}
+	return replies, nil
+}
+
+// RevokeSession 吊销用户的会话, sessionId 为空时吊销用户的全部会话
+func (as *AuthAppSvc) RevokeSession(userId int64, sessionId string) error {
+	if userId == 0 {
+		return errcode.ErrParams
+	}
+	if sessionId == "" {
+		return as.userDomainSvc.RevokeAllSessions(userId)
+	}
+	return as.userDomainSvc.RevokeSession(userId, sessionId)
+}
+
+func (as *AuthAppSvc) Introspect(token, audience string) (*reply.IntrospectReply, error) {
+	if token == "" {
+		return nil, errcode.ErrParams
+	}
+	introspection, err := as.userDomainSvc.IntrospectToken(token, audience)
+	if err != nil {
+		return nil, err
+	}
+	introspectReply := &reply.IntrospectReply{
+		Active:    introspection.Active,
+		TokenType: introspection.TokenType,
+		UserId:    introspection.UserId,
+		SessionId: introspection.SessionId,
+		Platform:  introspection.Platform,
+		Scope:     introspection.Scope,
+		Audience:  introspection.Audience,
+	}
+	for actor := introspection.Actor; actor != nil; actor = actor.Actor {
+		introspectReply.Actors = append(introspectReply.Actors, actor.Subject)
+	}
+	return introspectReply, nil
+}
@@ -0,0 +1,125 @@
+package appservice
+
+import (
+	"context"
+	"github.com/ljinf/user_auth/api/reply"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/common/util/localcache"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/cache"
+	"github.com/ljinf/user_auth/logic/do"
+	"github.com/ljinf/user_auth/logic/domainservice"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 网关转发认证在本地缓存验证通过的结果, 只缓存通过的结果, 无效的Token每次都到Redis中验证
+// 收到吊销事件时记录被吊销的用户、会话、API Key和吊销时间, 在吊销之前缓存的结果不再使用
+var (
+	forwardAuthCache     *localcache.Cache[string, *forwardAuthEntry]
+	forwardAuthRevoked   *localcache.Cache[string, time.Time]
+	forwardAuthCacheOnce sync.Once
+)
+
+type forwardAuthEntry struct {
+	authReply *reply.ForwardAuthReply
+	verifyAt  time.Time // 开始验证Token的时间, 在这之后发生的吊销都会让缓存失效
+}
+
+func getForwardAuthCache() (*localcache.Cache[string, *forwardAuthEntry], *localcache.Cache[string, time.Time]) {
+	forwardAuthCacheOnce.Do(func() {
+		forwardAuthCache = localcache.New[string, *forwardAuthEntry](config.Auth.ForwardAuthCacheEntries)
+		forwardAuthRevoked = localcache.New[string, time.Time](config.Auth.ForwardAuthCacheEntries)
+	})
+	return forwardAuthCache, forwardAuthRevoked
+}
+
+// ForwardAuth 网关转发认证, 验证AccessToken或API Key并返回需要透传给上游服务的身份信息
+// 与认证中间件使用同样的认证方式
+func (us *UserAppSvc) ForwardAuth(token string) (*reply.ForwardAuthReply, error) {
+	cacheKey := util.Sha256Hex(token)
+	authCache, revoked := getForwardAuthCache()
+	if entry, ok := authCache.Get(cacheKey); ok {
+		if !revokedSince(revoked, entry) {
+			return entry.authReply, nil
+		}
+		authCache.Delete(cacheKey)
+	}
+	verifyAt := time.Now()
+	principal, err := domainservice.NewAuthDomainSvc(us.ctx).Authenticate(token)
+	if err != nil {
+		return nil, errcode.Wrap("AuthenticateErr", err)
+	}
+	if principal == nil {
+		return nil, errcode.ErrToken
+	}
+	authReply := &reply.ForwardAuthReply{
+		PrincipalType: principal.Type,
+		UserId:        principal.UserId,
+		SessionId:     principal.SessionId,
+		Platform:      principal.Platform,
+		ApiKeyId:      principal.ApiKeyId,
+		Scope:         principal.Scope,
+	}
+	if config.Auth.ForwardAuthCacheTTL > 0 {
+		authCache.Set(cacheKey, &forwardAuthEntry{authReply: authReply, verifyAt: verifyAt}, config.Auth.ForwardAuthCacheTTL)
+	}
+	return authReply, nil
+}
+
+// SubscribeForwardAuthRevocations 订阅会话吊销事件, 及时让网关转发认证的本地缓存失效
+// 阻塞到ctx结束, 订阅断开时间隔一段时间后重新订阅
+func SubscribeForwardAuthRevocations(ctx context.Context) {
+	_, revoked := getForwardAuthCache()
+	for {
+		err := cache.SubscribeSessionRevoked(ctx, func(event *do.SessionRevokedEvent) {
+			if config.Auth.ForwardAuthCacheTTL <= 0 {
+				return
+			}
+			// 吊销记录保留到吊销前缓存的结果都过期为止
+			revoked.Set(revokedKey(event.UserId, event.SessionId, event.ApiKeyId), time.Now(), config.Auth.ForwardAuthCacheTTL)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		logger.New().Warn(ctx, "SubscribeSessionRevokedErr", "err", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// revokedSince 缓存的结果在验证之后, 所属的用户、会话或API Key是否被吊销过
+func revokedSince(revoked *localcache.Cache[string, time.Time], entry *forwardAuthEntry) bool {
+	authReply := entry.authReply
+	keys := []string{revokedKey(authReply.UserId, "", 0)}
+	if authReply.SessionId != "" {
+		keys = append(keys, revokedKey(authReply.UserId, authReply.SessionId, 0))
+	}
+	if authReply.ApiKeyId != 0 {
+		keys = append(keys, revokedKey(authReply.UserId, "", authReply.ApiKeyId))
+	}
+	for _, key := range keys {
+		if revokedAt, ok := revoked.Get(key); ok && !revokedAt.Before(entry.verifyAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// revokedKey 吊销记录的key, sessionId 为空且 apiKeyId 为0时表示用户的全部会话
+func revokedKey(userId int64, sessionId string, apiKeyId int64) string {
+	key := strconv.FormatInt(userId, 10)
+	if sessionId != "" {
+		return key + ":session:" + sessionId
+	}
+	if apiKeyId != 0 {
+		return key + ":apikey:" + strconv.FormatInt(apiKeyId, 10)
+	}
+	return key
+}
@@ -0,0 +1,136 @@
+package appservice
+
+import (
+	"context"
+	"github.com/ljinf/user_auth/api/reply"
+	"github.com/ljinf/user_auth/api/request"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/logic/do"
+	"github.com/ljinf/user_auth/logic/domainservice"
+)
+
+// OAuth 2.0 协议中定义的 grant_type
+const (
+	grantTypeDeviceCode    = "urn:ietf:params:oauth:grant-type:device_code"
+	grantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+// RFC 8693 3 定义的Token类型, 目前只支持交换 AccessToken
+const tokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+
+type OAuthAppSvc struct {
+	ctx           context.Context
+	userDomainSvc *domainservice.UserDomainSvc
+}
+
+func NewOAuthAppSvc(ctx context.Context) *OAuthAppSvc {
+	return &OAuthAppSvc{
+		ctx:           ctx,
+		userDomainSvc: domainservice.NewUserDomainSvc(ctx),
+	}
+}
+
+// DeviceCode 设备申请授权
+func (os *OAuthAppSvc) DeviceCode(req *request.DeviceCode) (*reply.DeviceCodeReply, error) {
+	deviceCode, err := domainservice.NewDeviceAuthDomainSvc(os.ctx).RequestCode(req.ClientId, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+	deviceCodeReply := new(reply.DeviceCodeReply)
+	util.CopyProperties(deviceCodeReply, deviceCode)
+	return deviceCodeReply, nil
+}
+
+// DeviceVerifyInfo 用户输入 user_code 后获取申请授权的设备信息
+func (os *OAuthAppSvc) DeviceVerifyInfo(req *request.DeviceVerifyQuery) (*reply.DeviceVerifyReply, error) {
+	auth, client, err := domainservice.NewDeviceAuthDomainSvc(os.ctx).GetByUserCode(req.UserCode)
+	if err != nil {
+		return nil, err
+	}
+	verifyReply := &reply.DeviceVerifyReply{
+		ClientId: auth.ClientId,
+		Scope:    auth.Scope,
+		UserCode: req.UserCode,
+	}
+	if client != nil {
+		verifyReply.ClientName = client.Name
+	}
+	return verifyReply, nil
+}
+
+// DeviceVerify 用户同意或拒绝设备的授权请求
+func (os *OAuthAppSvc) DeviceVerify(userId int64, req *request.DeviceVerify) error {
+	return domainservice.NewDeviceAuthDomainSvc(os.ctx).Decide(req.UserCode, userId, req.Approve)
+}
+
+// Token OAuth 2.0 Token接口, 按 grant_type 分发
+func (os *OAuthAppSvc) Token(req *request.OAuthToken, client *request.ClientInfo) (*reply.OAuthTokenReply, error) {
+	switch req.GrantType {
+	case grantTypeDeviceCode:
+		return os.deviceCodeToken(req, client)
+	case grantTypeTokenExchange:
+		return os.tokenExchange(req, client)
+	default:
+		return nil, errcode.ErrOAuthUnsupportedGrantType
+	}
+}
+
+func (os *OAuthAppSvc) deviceCodeToken(req *request.OAuthToken, client *request.ClientInfo) (*reply.OAuthTokenReply, error) {
+	if req.DeviceCode == "" || req.ClientId == "" {
+		return nil, errcode.ErrOAuthInvalidRequest
+	}
+	auth, deviceClient, err := domainservice.NewDeviceAuthDomainSvc(os.ctx).PollToken(req.DeviceCode, req.ClientId)
+	if err != nil {
+		return nil, err
+	}
+	attempt := &do.LoginAttempt{
+		UserId:    auth.UserId,
+		Platform:  deviceClient.Platform,
+		Ip:        client.Ip,
+		UserAgent: client.UserAgent,
+		DeviceId:  client.DeviceId,
+		// 设备得到的是只能使用授权scope的受限会话
+		Scope: auth.Scope,
+	}
+	token, err := os.userDomainSvc.Login(attempt)
+	if err == errcode.ErrUserInvalid || err == errcode.ErrLoginDenied || err == errcode.ErrLoginNeedStepUp {
+		// 授权后用户被封禁或者被风控拒绝, 设备上无法完成二次验证, 需要二次验证时同样拒绝
+		return nil, errcode.ErrDeviceAccessDenied
+	}
+	if err != nil {
+		return nil, err
+	}
+	logger.New().Info(os.ctx, "device authorization login success", "userId", auth.UserId, "clientId", req.ClientId)
+	return &reply.OAuthTokenReply{
+		AccessToken:  token.AccessToken,
+		TokenType:    enum.TokenTypeBearer,
+		ExpiresIn:    token.Duration,
+		RefreshToken: token.RefreshToken,
+		Scope:        auth.Scope,
+	}, nil
+}
+
+func (os *OAuthAppSvc) tokenExchange(req *request.OAuthToken, client *request.ClientInfo) (*reply.OAuthTokenReply, error) {
+	if req.SubjectToken == "" || req.SubjectTokenType != tokenTypeAccessToken || req.Audience == "" {
+		return nil, errcode.ErrOAuthInvalidRequest
+	}
+	exchangeDomainSvc := domainservice.NewTokenExchangeDomainSvc(os.ctx)
+	exchangeClient, err := exchangeDomainSvc.AuthenticateClient(req.ClientId, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	token, err := exchangeDomainSvc.Exchange(exchangeClient, req.SubjectToken, req.Audience, req.Scope, client.Ip)
+	if err != nil {
+		return nil, err
+	}
+	return &reply.OAuthTokenReply{
+		AccessToken:     token.AccessToken,
+		TokenType:       enum.TokenTypeBearer,
+		ExpiresIn:       token.ExpiresIn,
+		Scope:           token.Scope,
+		IssuedTokenType: tokenTypeAccessToken,
+	}, nil
+}
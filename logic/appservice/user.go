@@ -3,8 +3,14 @@ package appservice
 import (
 	"context"
 	"github.com/ljinf/user_auth/api/reply"
+	"github.com/ljinf/user_auth/api/request"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/errcode"
 	"github.com/ljinf/user_auth/common/logger"
 	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/library"
+	"github.com/ljinf/user_auth/logic/do"
 	"github.com/ljinf/user_auth/logic/domainservice"
 )
 
@@ -21,7 +27,7 @@ func NewUserAppSvc(ctx context.Context) *UserAppSvc {
 }
 
 func (us *UserAppSvc) GenToken() (*reply.TokenReply, error) {
-	token, err := us.userDomainSvc.GenAuthToken(12345678, "h5", "")
+	token, err := us.userDomainSvc.GenAuthToken(12345678, "h5", "", "")
 	if err != nil {
 		return nil, err
 	}
@@ -31,6 +37,21 @@ func (us *UserAppSvc) GenToken() (*reply.TokenReply, error) {
 	return tokenReply, err
 }
 
+// GetUserBaseInfo 内部服务查询用户的基本信息
+func (us *UserAppSvc) GetUserBaseInfo(userId int64) (*reply.UserBaseInfoReply, error) {
+	userInfo, err := us.userDomainSvc.GetUserBaseInfo(userId)
+	if err != nil {
+		return nil, err
+	}
+	if userInfo.ID == 0 {
+		return nil, errcode.ErrUserNotRegistered
+	}
+	userInfoReply := new(reply.UserBaseInfoReply)
+	util.CopyProperties(userInfoReply, userInfo)
+	userInfoReply.Id = userInfo.ID
+	return userInfoReply, nil
+}
+
 func (us *UserAppSvc) TokenRefresh(refreshToken string) (*reply.TokenReply, error) {
 	token, err := us.userDomainSvc.RefreshToken(refreshToken)
 	if err != nil {
@@ -41,3 +62,212 @@ func (us *UserAppSvc) TokenRefresh(refreshToken string) (*reply.TokenReply, erro
 	util.CopyProperties(tokenReply, token)
 	return tokenReply, err
 }
+
+// SendSmsLoginCode 发送短信登录验证码
+// 不管手机号是否注册都会发送, 避免接口被用来探测手机号是否已注册
+func (us *UserAppSvc) SendSmsLoginCode(req *request.SmsCodeSend) error {
+	return domainservice.NewVerifyCodeDomainSvc(us.ctx).SendSmsCode(enum.VerifyCodeSceneLogin, req.Phone)
+}
+
+// SmsCodeLogin 短信验证码登录
+func (us *UserAppSvc) SmsCodeLogin(req *request.SmsCodeLogin, client *request.ClientInfo) (*reply.TokenReply, error) {
+	user, err := us.userDomainSvc.GetUserBaseInfoByPhone(req.Phone)
+	if err != nil {
+		return nil, err
+	}
+	return us.verifyCodeLogin(user, req.Phone, req.Code, req.Platform, client)
+}
+
+// SendEmailLoginCode 发送邮件登录验证码
+// 不管邮箱是否注册都会发送, 避免接口被用来探测邮箱是否已注册
+func (us *UserAppSvc) SendEmailLoginCode(req *request.EmailCodeSend, client *request.ClientInfo) error {
+	locale := library.MatchMailLocale(client.Locale)
+	return domainservice.NewVerifyCodeDomainSvc(us.ctx).SendEmailCode(enum.VerifyCodeSceneLogin, req.Email, locale)
+}
+
+// EmailCodeLogin 邮件验证码登录
+func (us *UserAppSvc) EmailCodeLogin(req *request.EmailCodeLogin, client *request.ClientInfo) (*reply.TokenReply, error) {
+	user, err := us.userDomainSvc.GetUserBaseInfoByEmail(req.Email)
+	if err != nil {
+		return nil, err
+	}
+	return us.verifyCodeLogin(user, req.Email, req.Code, req.Platform, client)
+}
+
+// SendVerifyEmailCode 向用户绑定的邮箱发送邮箱验证的验证码
+func (us *UserAppSvc) SendVerifyEmailCode(userId int64, client *request.ClientInfo) error {
+	user, err := us.userDomainSvc.GetUserBaseInfo(userId)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" {
+		return errcode.ErrEmailNotBound
+	}
+	if user.Verified == enum.UserVerifiedYes {
+		return errcode.ErrEmailAlreadyVerified
+	}
+	locale := library.MatchMailLocale(client.Locale)
+	return domainservice.NewVerifyCodeDomainSvc(us.ctx).SendEmailCode(enum.VerifyCodeSceneVerifyEmail, user.Email, locale)
+}
+
+// VerifyEmail 校验邮箱验证码, 通过后把用户标记为已认证
+func (us *UserAppSvc) VerifyEmail(userId int64, req *request.EmailVerify) error {
+	user, err := us.userDomainSvc.GetUserBaseInfo(userId)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" {
+		return errcode.ErrEmailNotBound
+	}
+	err = domainservice.NewVerifyCodeDomainSvc(us.ctx).CheckCode(enum.VerifyCodeSceneVerifyEmail, user.Email, req.Code)
+	if err != nil {
+		return err
+	}
+	return us.userDomainSvc.MarkUserVerified(userId)
+}
+
+// ForgotPassword 申请找回密码, 重置密码的链接通过邮件或者短信发送
+func (us *UserAppSvc) ForgotPassword(req *request.PasswordForgot, client *request.ClientInfo) error {
+	locale := library.MatchMailLocale(client.Locale)
+	return domainservice.NewPasswordDomainSvc(us.ctx).SendResetToken(req.Account, locale)
+}
+
+// ResetPassword 通过重置密码链接中的Token设置新密码
+func (us *UserAppSvc) ResetPassword(req *request.PasswordReset, client *request.ClientInfo) error {
+	return domainservice.NewPasswordDomainSvc(us.ctx).ResetPassword(req.Token, req.NewPassword, client.Ip)
+}
+
+// ChangePassword 已登录用户修改密码
+func (us *UserAppSvc) ChangePassword(userId int64, sessionId string, req *request.PasswordChange, client *request.ClientInfo) error {
+	return domainservice.NewPasswordDomainSvc(us.ctx).ChangePassword(userId, sessionId,
+		req.CurrentPassword, req.NewPassword, req.Code, req.LogoutOthers, client.Ip)
+}
+
+// SendSetPasswordCode 还没有设置过密码的用户设置密码前, 向绑定的手机号或邮箱发送验证码
+func (us *UserAppSvc) SendSetPasswordCode(userId int64, client *request.ClientInfo) error {
+	locale := library.MatchMailLocale(client.Locale)
+	return domainservice.NewPasswordDomainSvc(us.ctx).SendSetPasswordCode(userId, locale)
+}
+
+// SendMagicLink 发送H5邮件登录链接, 返回需要写入浏览器Cookie的设备绑定值
+func (us *UserAppSvc) SendMagicLink(req *request.MagicLinkSend, client *request.ClientInfo) (string, error) {
+	locale := library.MatchMailLocale(client.Locale)
+	return domainservice.NewMagicLinkDomainSvc(us.ctx).Send(req.Email, locale)
+}
+
+// MagicLinkLogin 使用邮件中的登录链接登录H5
+func (us *UserAppSvc) MagicLinkLogin(req *request.MagicLinkLogin, deviceBinding string, client *request.ClientInfo) (*reply.TokenReply, error) {
+	userId, err := domainservice.NewMagicLinkDomainSvc(us.ctx).Consume(req.Token, deviceBinding)
+	if err != nil {
+		return nil, err
+	}
+	attempt := &do.LoginAttempt{
+		UserId:    userId,
+		Platform:  enum.PlatformH5,
+		Ip:        client.Ip,
+		UserAgent: client.UserAgent,
+		DeviceId:  client.DeviceId,
+		// 登录链接只能证明持有链接, 风险较高时仍然要求通过验证码登录完成二次验证
+	}
+	token, err := us.userDomainSvc.Login(attempt)
+	if err != nil {
+		return nil, err
+	}
+	logger.New().Info(us.ctx, "magic link login success", "userId", userId)
+	tokenReply := new(reply.TokenReply)
+	util.CopyProperties(tokenReply, token)
+	return tokenReply, nil
+}
+
+// CreateQrTicket PC端申请扫码登录的二维码
+func (us *UserAppSvc) CreateQrTicket() (*reply.QrTicketReply, error) {
+	ticket, secret, err := domainservice.NewQrLoginDomainSvc(us.ctx).CreateTicket()
+	if err != nil {
+		return nil, err
+	}
+	return &reply.QrTicketReply{
+		Ticket:    ticket,
+		Secret:    secret,
+		ExpiresIn: int64(config.QrLogin.TTL.Seconds()),
+	}, nil
+}
+
+// PollQrTicket PC端查询二维码状态, App确认登录后返回PC平台的Token
+func (us *UserAppSvc) PollQrTicket(req *request.QrTicketPoll, client *request.ClientInfo) (*reply.QrTicketStatusReply, error) {
+	status, userId, err := domainservice.NewQrLoginDomainSvc(us.ctx).Poll(req.Ticket, req.Secret)
+	if err != nil {
+		return nil, err
+	}
+	statusReply := &reply.QrTicketStatusReply{Status: status}
+	if status != enum.QrTicketStatusConfirmed {
+		return statusReply, nil
+	}
+	attempt := &do.LoginAttempt{
+		UserId:    userId,
+		Platform:  enum.PlatformPC,
+		Ip:        client.Ip,
+		UserAgent: client.UserAgent,
+		DeviceId:  client.DeviceId,
+		// App确认只能证明持有二维码, PC端所在环境风险较高时仍然要求通过验证码登录完成二次验证
+	}
+	token, err := us.userDomainSvc.Login(attempt)
+	if err != nil {
+		return nil, err
+	}
+	logger.New().Info(us.ctx, "qr code login success", "userId", userId)
+	statusReply.Token = new(reply.TokenReply)
+	util.CopyProperties(statusReply.Token, token)
+	return statusReply, nil
+}
+
+// QrTicketStatus PC端通过SSE等待二维码状态变化, 只返回状态不发放Token
+// 状态为 confirmed 后PC端再调用 PollQrTicket 拿到Token, 两种方式发放Token时都能写入会话Cookie
+func (us *UserAppSvc) QrTicketStatus(req *request.QrTicketPoll) (*reply.QrTicketStatusReply, error) {
+	status, err := domainservice.NewQrLoginDomainSvc(us.ctx).Status(req.Ticket, req.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return &reply.QrTicketStatusReply{Status: status}, nil
+}
+
+// ScanQrTicket App用户扫描PC端的二维码
+func (us *UserAppSvc) ScanQrTicket(userId int64, req *request.QrTicketScan) error {
+	return domainservice.NewQrLoginDomainSvc(us.ctx).Scan(req.Ticket, userId)
+}
+
+// ConfirmQrTicket App用户确认登录PC端
+func (us *UserAppSvc) ConfirmQrTicket(userId int64, req *request.QrTicketScan) error {
+	return domainservice.NewQrLoginDomainSvc(us.ctx).Confirm(req.Ticket, userId)
+}
+
+// verifyCodeLogin 短信、邮件验证码登录的公共逻辑, target为接收验证码的手机号或邮箱
+func (us *UserAppSvc) verifyCodeLogin(user *do.UserBaseInfo, target, code, platform string, client *request.ClientInfo) (*reply.TokenReply, error) {
+	attempt := &do.LoginAttempt{
+		UserId:    user.ID,
+		Platform:  platform,
+		Ip:        client.Ip,
+		UserAgent: client.UserAgent,
+		DeviceId:  client.DeviceId,
+		// 二次验证就是短信、邮件验证码, 验证码登录本身已经完成了二次验证
+		StepUpPassed: true,
+	}
+	err := domainservice.NewVerifyCodeDomainSvc(us.ctx).CheckCode(enum.VerifyCodeSceneLogin, target, code)
+	if err != nil {
+		if user.ID != 0 && (err == errcode.ErrVerifyCodeInvalid || err == errcode.ErrVerifyCodeAttemptsExceeded) {
+			// 验证码错误计入用户的登录失败次数
+			domainservice.NewRiskDomainSvc(us.ctx).RecordLoginFailure(attempt)
+		}
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errcode.ErrUserNotRegistered
+	}
+	token, err := us.userDomainSvc.Login(attempt)
+	if err != nil {
+		return nil, err
+	}
+	logger.New().Info(us.ctx, "verify code login success", "userId", user.ID, "platform", platform)
+	tokenReply := new(reply.TokenReply)
+	util.CopyProperties(tokenReply, token)
+	return tokenReply, nil
+}
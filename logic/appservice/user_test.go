@@ -0,0 +1,116 @@
+package appservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ljinf/user_auth/api/request"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/cache"
+	"github.com/ljinf/user_auth/dal/model"
+)
+
+// TestPollQrTicketStepUp 扫码登录只能证明持有二维码, 风险评分达到二次验证的分数时不发放Token
+func TestPollQrTicketStepUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int64 // 窗口内登录失败次数, 达到阈值时评分达到二次验证的分数
+		wantErr  error
+	}{
+		{name: "low risk", failures: 0},
+		{name: "high risk", failures: config.Risk.FailureThreshold, wantErr: errcode.ErrLoginNeedStepUp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTestData(t)
+			ctx := context.Background()
+			user := createTestUser(t, &model.User{Nickname: "test"})
+			for i := int64(0); i < tt.failures; i++ {
+				if _, err := cache.IncrLoginFailure(ctx, user.Id, config.Risk.FailureWindow); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			svc := NewUserAppSvc(ctx)
+			ticket, err := svc.CreateQrTicket()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = svc.ScanQrTicket(user.Id, &request.QrTicketScan{Ticket: ticket.Ticket}); err != nil {
+				t.Fatal(err)
+			}
+			if err = svc.ConfirmQrTicket(user.Id, &request.QrTicketScan{Ticket: ticket.Ticket}); err != nil {
+				t.Fatal(err)
+			}
+			// 内网IP不会请求外部的IP归属地服务
+			client := &request.ClientInfo{Ip: "10.0.0.1"}
+			status, err := svc.PollQrTicket(&request.QrTicketPoll{Ticket: ticket.Ticket, Secret: ticket.Secret}, client)
+			if err != tt.wantErr {
+				t.Fatalf("PollQrTicket() err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if status.Status != enum.QrTicketStatusConfirmed || status.Token == nil || status.Token.AccessToken == "" {
+				t.Errorf("PollQrTicket() = %+v, want confirmed with token", status)
+			}
+		})
+	}
+}
+
+// TestQrTicketStatusKeepsTicket SSE推送状态时不消费二维码, 确认后仍然可以通过轮询拿到Token
+func TestQrTicketStatusKeepsTicket(t *testing.T) {
+	resetTestData(t)
+	ctx := context.Background()
+	user := createTestUser(t, &model.User{Nickname: "test"})
+	svc := NewUserAppSvc(ctx)
+	ticket, err := svc.CreateQrTicket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	poll := &request.QrTicketPoll{Ticket: ticket.Ticket, Secret: ticket.Secret}
+
+	steps := []struct {
+		name string
+		run  func() error
+		want string
+	}{
+		{name: "pending", want: enum.QrTicketStatusPending},
+		{name: "scanned", run: func() error {
+			return svc.ScanQrTicket(user.Id, &request.QrTicketScan{Ticket: ticket.Ticket})
+		}, want: enum.QrTicketStatusScanned},
+		{name: "confirmed", run: func() error {
+			return svc.ConfirmQrTicket(user.Id, &request.QrTicketScan{Ticket: ticket.Ticket})
+		}, want: enum.QrTicketStatusConfirmed},
+		{name: "confirmed again", want: enum.QrTicketStatusConfirmed},
+	}
+	for _, step := range steps {
+		if step.run != nil {
+			if err = step.run(); err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+		}
+		status, err := svc.QrTicketStatus(poll)
+		if err != nil {
+			t.Fatalf("%s: QrTicketStatus() err = %v", step.name, err)
+		}
+		if status.Status != step.want || status.Token != nil {
+			t.Fatalf("%s: QrTicketStatus() = %+v, want %s without token", step.name, status, step.want)
+		}
+	}
+
+	status, err := svc.PollQrTicket(poll, &request.ClientInfo{Ip: "10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != enum.QrTicketStatusConfirmed || status.Token == nil {
+		t.Errorf("PollQrTicket() = %+v, want confirmed with token", status)
+	}
+	// 轮询拿到Token后二维码失效
+	status, err = svc.QrTicketStatus(poll)
+	if err != nil || status.Status != enum.QrTicketStatusExpired {
+		t.Errorf("QrTicketStatus() after poll = %+v, %v, want expired", status, err)
+	}
+}
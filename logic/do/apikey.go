@@ -0,0 +1,14 @@
+package do
+
+import "time"
+
+// ApiKey 机器客户端使用的API Key, 不包含Key本身
+type ApiKey struct {
+	Id        int64      `json:"id"`
+	UserId    int64      `json:"user_id"`
+	Name      string     `json:"name"`
+	KeyPrefix string     `json:"key_prefix"`
+	Scope     string     `json:"scope"`
+	ExpiredAt *time.Time `json:"expired_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,9 @@
+package do
+
+// AuditEvent 需要记录到审计日志中的用户安全事件
+type AuditEvent struct {
+	UserId int64
+	Event  string
+	Ip     string
+	Detail map[string]interface{}
+}
@@ -0,0 +1,20 @@
+package do
+
+// DeviceAuthorization 设备授权(RFC 8628)中一次授权请求的信息
+type DeviceAuthorization struct {
+	ClientId string
+	Scope    string
+	UserCode string
+	Status   string // pending, approved, denied
+	UserId   int64  // 完成授权的用户
+}
+
+// DeviceCode 设备申请授权的结果
+type DeviceCode struct {
+	DeviceCode              string
+	UserCode                string // 展示给用户的格式: XXXX-XXXX
+	VerificationUri         string
+	VerificationUriComplete string
+	ExpiresIn               int64
+	Interval                int64
+}
@@ -0,0 +1,7 @@
+package do
+
+// MagicLink 邮件登录链接在缓存中保存的信息
+type MagicLink struct {
+	UserId     int64  `json:"user_id"`
+	DeviceHash string `json:"device_hash"` // 绑定的浏览器Cookie的哈希, 为空表示不绑定设备
+}
@@ -0,0 +1,8 @@
+package do
+
+// QrTicket PC扫码登录的二维码
+type QrTicket struct {
+	Status     string // pending, scanned, confirmed, expired
+	UserId     int64  // 扫码的App用户
+	SecretHash string // PC端轮询时需要提供的密钥的哈希, 防止只拿到二维码内容的人冒领Token
+}
@@ -0,0 +1,23 @@
+package do
+
+// LoginAttempt 一次登录尝试的上下文信息, 由接口层从请求中收集
+type LoginAttempt struct {
+	UserId       int64
+	Platform     string
+	Ip           string
+	UserAgent    string
+	DeviceId     string
+	StepUpPassed bool   // 本次登录是否已通过了短信、邮件验证码等二次验证
+	Scope        string // 登录得到的会话允许的scope, 设备授权登录时为用户同意的scope, 为空表示完整的登录会话
+}
+
+// RiskAssessment 风控引擎对一次登录尝试的评估结果
+type RiskAssessment struct {
+	Score       int
+	Action      string   // allow, step_up, deny
+	Reasons     []string // 命中的风险项
+	CountryCode string
+	City        string
+	Latitude    float64
+	Longitude   float64
+}
@@ -0,0 +1,21 @@
+package do
+
+// ExchangedToken Token交换的结果
+type ExchangedToken struct {
+	AccessToken string
+	ExpiresIn   int64 // 有效期, 单位秒
+	Scope       string
+	Audience    string
+}
+
+// TokenIntrospection Token的详细信息, 参考 RFC 7662
+type TokenIntrospection struct {
+	Active    bool
+	TokenType string // access_token, exchanged_token
+	UserId    int64
+	SessionId string
+	Platform  string
+	Scope     string
+	Audience  string
+	Actor     *Actor
+}
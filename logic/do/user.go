@@ -10,6 +10,17 @@ type SessionInfo struct {
 	Email        string `json:"email"`
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+	// Token允许的scope, 空格分隔. 设备授权登录的会话和Token交换得到的Token才有, 为空表示完整的登录会话
+	Scope string `json:"scope,omitempty"`
+	// 以下字段只有通过Token交换得到的Token才有
+	Audience string `json:"audience,omitempty"` // Token的目标服务
+	Actor    *Actor `json:"act,omitempty"`      // 代表用户调用的服务链条
+}
+
+// Actor Token交换中代表用户发起调用的服务, 多次交换时嵌套记录完整的调用链条, 参见 RFC 8693 4.1
+type Actor struct {
+	Subject string `json:"sub"`
+	Actor   *Actor `json:"act,omitempty"`
 }
 
 type TokenInfo struct {
@@ -23,6 +34,8 @@ type UserBaseInfo struct {
 	ID        int64     `json:"id"`
 	Nickname  string    `json:"nickname"`
 	LoginName string    `json:"login_name"`
+	Phone     string    `json:"phone"`
+	Email     string    `json:"email"`
 	Verified  int       `json:"verified"`
 	Avatar    string    `json:"avatar"`
 	Slogan    string    `json:"slogan"`
@@ -35,4 +48,24 @@ type TokenVerify struct {
 	Approved  bool   // 验证结果
 	UserId    int64  // 用户ID
 	SessionId string // SessionId 可以用于存储一些与登录相关的东西, 用户不重新登录不会变
+	Platform  string // 登录的平台
+	Scope     string // 受限会话允许的scope, 为空表示完整的登录会话
+}
+
+// Principal 请求携带的Token认证通过后得到的身份
+type Principal struct {
+	Type      string // enum.PrincipalTypeUser 或 enum.PrincipalTypeApiKey
+	UserId    int64
+	SessionId string // 用户登录的会话才有
+	Platform  string // 用户登录的会话才有
+	ApiKeyId  int64  // API Key才有
+	Scope     string // API Key和受限会话允许的scope, 空格分隔
+}
+
+// SessionRevokedEvent 会话被吊销的事件, SessionId 为空表示用户的全部会话都被吊销
+// ApiKeyId 不为0表示用户的API Key被吊销或轮换, 不认识这个字段的订阅方会按吊销用户的全部会话处理
+type SessionRevokedEvent struct {
+	UserId    int64  `json:"user_id"`
+	SessionId string `json:"session_id,omitempty"`
+	ApiKeyId  int64  `json:"api_key_id,omitempty"`
 }
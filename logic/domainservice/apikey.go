@@ -0,0 +1,225 @@
+package domainservice
+
+import (
+	"context"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/cache"
+	"github.com/ljinf/user_auth/dal/dao"
+	"github.com/ljinf/user_auth/dal/model"
+	"github.com/ljinf/user_auth/logic/do"
+	"strings"
+	"time"
+)
+
+// ApiKeyDomainSvc 机器客户端使用的API Key
+// Key只在创建和轮换时返回一次, 存储时只保存SHA256哈希
+type ApiKeyDomainSvc struct {
+	ctx context.Context
+}
+
+func NewApiKeyDomainSvc(ctx context.Context) *ApiKeyDomainSvc {
+	return &ApiKeyDomainSvc{ctx: ctx}
+}
+
+// Create 为用户创建API Key, operatorId 为操作人, 管理员代用户创建时与 userId 不同
+func (as *ApiKeyDomainSvc) Create(operatorId, userId int64, name, scope string, expiredAt *time.Time, ip string) (*do.ApiKey, string, error) {
+	grantedScope, ok := narrowScope(scope, config.ApiKey.Scopes)
+	if !ok {
+		return nil, "", errcode.ErrParams.WithFields(&errcode.FieldError{Field: "scope", Rule: "allowed", Msg: "scope不在允许的范围内"})
+	}
+	if expiredAt != nil && !expiredAt.After(time.Now()) {
+		return nil, "", errcode.ErrParams.WithFields(&errcode.FieldError{Field: "expired_at", Rule: "future", Msg: "过期时间必须晚于当前时间"})
+	}
+	apiKeyDao := dao.NewApiKeyDao(as.ctx)
+	count, err := apiKeyDao.CountUserApiKeys(userId)
+	if err != nil {
+		return nil, "", errcode.Wrap("CountUserApiKeysErr", err)
+	}
+	if count >= int64(config.ApiKey.MaxPerUser) {
+		return nil, "", errcode.ErrApiKeyLimitExceeded
+	}
+
+	key, keyPrefix, err := genApiKey()
+	if err != nil {
+		return nil, "", errcode.Wrap("GenApiKeyErr", err)
+	}
+	apiKey := &model.UserApiKey{
+		UserId:    userId,
+		Name:      name,
+		KeyPrefix: keyPrefix,
+		KeyHash:   util.Sha256Hex(key),
+		Scope:     grantedScope,
+		ExpiredAt: expiredAt,
+		CreatedBy: operatorId,
+	}
+	if err = apiKeyDao.CreateApiKey(apiKey); err != nil {
+		return nil, "", errcode.Wrap("CreateApiKeyErr", err)
+	}
+	as.audit(operatorId, enum.AuditEventApiKeyCreate, apiKey, ip)
+	return toApiKey(apiKey), key, nil
+}
+
+func (as *ApiKeyDomainSvc) List(userId int64) ([]*do.ApiKey, error) {
+	apiKeys, err := dao.NewApiKeyDao(as.ctx).ListUserApiKeys(userId)
+	if err != nil {
+		return nil, errcode.Wrap("ListUserApiKeysErr", err)
+	}
+	result := make([]*do.ApiKey, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		result = append(result, toApiKey(apiKey))
+	}
+	return result, nil
+}
+
+// Rotate 为API Key生成新的Key, 名称、scope和过期时间不变, 旧Key立即失效
+func (as *ApiKeyDomainSvc) Rotate(operatorId, userId, keyId int64, ip string) (*do.ApiKey, string, error) {
+	apiKeyDao := dao.NewApiKeyDao(as.ctx)
+	apiKey, err := apiKeyDao.FindUserApiKey(userId, keyId)
+	if err != nil {
+		return nil, "", errcode.Wrap("FindUserApiKeyErr", err)
+	}
+	if apiKey == nil {
+		return nil, "", errcode.ErrApiKeyNotFound
+	}
+	key, keyPrefix, err := genApiKey()
+	if err != nil {
+		return nil, "", errcode.Wrap("GenApiKeyErr", err)
+	}
+	oldKeyHash := apiKey.KeyHash
+	apiKey.KeyPrefix, apiKey.KeyHash = keyPrefix, util.Sha256Hex(key)
+	if err = apiKeyDao.UpdateApiKeyHash(apiKey.Id, apiKey.KeyPrefix, apiKey.KeyHash); err != nil {
+		return nil, "", errcode.Wrap("UpdateApiKeyHashErr", err)
+	}
+	if err = cache.DelApiKey(as.ctx, oldKeyHash); err != nil {
+		return nil, "", errcode.Wrap("DelApiKeyErr", err)
+	}
+	as.publishApiKeyRevoked(apiKey)
+	as.audit(operatorId, enum.AuditEventApiKeyRotate, apiKey, ip)
+	return toApiKey(apiKey), key, nil
+}
+
+func (as *ApiKeyDomainSvc) Revoke(operatorId, userId, keyId int64, ip string) error {
+	apiKeyDao := dao.NewApiKeyDao(as.ctx)
+	apiKey, err := apiKeyDao.FindUserApiKey(userId, keyId)
+	if err != nil {
+		return errcode.Wrap("FindUserApiKeyErr", err)
+	}
+	if apiKey == nil {
+		return errcode.ErrApiKeyNotFound
+	}
+	if err = apiKeyDao.DeleteApiKey(apiKey.Id); err != nil {
+		return errcode.Wrap("DeleteApiKeyErr", err)
+	}
+	if err = cache.DelApiKey(as.ctx, apiKey.KeyHash); err != nil {
+		return errcode.Wrap("DelApiKeyErr", err)
+	}
+	as.publishApiKeyRevoked(apiKey)
+	as.audit(operatorId, enum.AuditEventApiKeyRevoke, apiKey, ip)
+	return nil
+}
+
+// publishApiKeyRevoked 通知网关转发认证等缓存了API Key验证结果的地方, 通知失败时缓存会在过期后自然失效
+func (as *ApiKeyDomainSvc) publishApiKeyRevoked(apiKey *model.UserApiKey) {
+	event := &do.SessionRevokedEvent{UserId: apiKey.UserId, ApiKeyId: apiKey.Id}
+	if err := cache.PublishSessionRevoked(as.ctx, event); err != nil {
+		logger.New().Error(as.ctx, "PublishSessionRevokedErr", "err", err, "event", event)
+	}
+}
+
+// Verify 校验请求中的API Key, Key无效或已过期时返回nil
+func (as *ApiKeyDomainSvc) Verify(key string) (*do.ApiKey, error) {
+	if !strings.HasPrefix(key, enum.ApiKeyPrefix) ||
+		len(key) != len(enum.ApiKeyPrefix)+enum.ApiKeyIdLength+1+enum.ApiKeySecretLen {
+		return nil, nil
+	}
+	keyHash := util.Sha256Hex(key)
+	apiKey, err := cache.GetApiKey(as.ctx, keyHash)
+	if err != nil {
+		logger.New().Error(as.ctx, "GetApiKeyErr", "err", err)
+	}
+	if apiKey == nil {
+		apiKey, err = as.loadApiKey(keyHash)
+		if err != nil || apiKey == nil {
+			return nil, err
+		}
+	}
+	if apiKey.ExpiredAt != nil && !apiKey.ExpiredAt.After(time.Now()) {
+		return nil, nil
+	}
+	return apiKey, nil
+}
+
+// loadApiKey 从数据库加载API Key并写入缓存, 缓存时间不超过Key的过期时间
+func (as *ApiKeyDomainSvc) loadApiKey(keyHash string) (*do.ApiKey, error) {
+	keyModel, err := dao.NewApiKeyDao(as.ctx).FindApiKeyByHash(keyHash)
+	if err != nil {
+		return nil, errcode.Wrap("FindApiKeyByHashErr", err)
+	}
+	if keyModel == nil {
+		return nil, nil
+	}
+	user, err := dao.NewUserDao(as.ctx).FindUserById(keyModel.UserId)
+	if err != nil {
+		return nil, errcode.Wrap("FindUserByIdErr", err)
+	}
+	if user == nil || user.IsBlocked == enum.UserBlockStateBlocked {
+		return nil, nil
+	}
+	apiKey := toApiKey(keyModel)
+	ttl := config.ApiKey.CacheTTL
+	if apiKey.ExpiredAt != nil {
+		if remaining := time.Until(*apiKey.ExpiredAt); remaining < ttl {
+			ttl = remaining
+		}
+	}
+	if ttl > 0 {
+		if err = cache.SetApiKey(as.ctx, keyHash, apiKey, ttl); err != nil {
+			logger.New().Error(as.ctx, "SetApiKeyErr", "err", err)
+		}
+	}
+	return apiKey, nil
+}
+
+func (as *ApiKeyDomainSvc) audit(operatorId int64, event string, apiKey *model.UserApiKey, ip string) {
+	NewAuditDomainSvc(as.ctx).Record(&do.AuditEvent{
+		UserId: apiKey.UserId,
+		Event:  event,
+		Ip:     ip,
+		Detail: map[string]interface{}{
+			"apiKeyId":   apiKey.Id,
+			"keyPrefix":  apiKey.KeyPrefix,
+			"operatorId": operatorId,
+		},
+	})
+}
+
+// genApiKey 生成API Key, 返回完整的Key和用于展示的前缀
+func genApiKey() (key, keyPrefix string, err error) {
+	keyId, err := util.SecureRandString(enum.ApiKeyIdLength, util.Alphanumeric)
+	if err != nil {
+		return
+	}
+	secret, err := util.SecureRandString(enum.ApiKeySecretLen, util.Alphanumeric)
+	if err != nil {
+		return
+	}
+	keyPrefix = enum.ApiKeyPrefix + keyId
+	key = keyPrefix + "_" + secret
+	return
+}
+
+func toApiKey(apiKey *model.UserApiKey) *do.ApiKey {
+	return &do.ApiKey{
+		Id:        apiKey.Id,
+		UserId:    apiKey.UserId,
+		Name:      apiKey.Name,
+		KeyPrefix: apiKey.KeyPrefix,
+		Scope:     apiKey.Scope,
+		ExpiredAt: apiKey.ExpiredAt,
+		CreatedAt: apiKey.CreatedAt,
+	}
+}
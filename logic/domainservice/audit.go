@@ -0,0 +1,36 @@
+package domainservice
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/dal/dao"
+	"github.com/ljinf/user_auth/dal/model"
+	"github.com/ljinf/user_auth/logic/do"
+)
+
+type AuditDomainSvc struct {
+	ctx context.Context
+}
+
+func NewAuditDomainSvc(ctx context.Context) *AuditDomainSvc {
+	return &AuditDomainSvc{ctx: ctx}
+}
+
+// Record 记录审计日志
+// 审计日志写入失败不影响主流程, 只记录一条错误日志
+func (as *AuditDomainSvc) Record(event *do.AuditEvent) {
+	detail, _ := json.Marshal(event.Detail)
+	auditLog := &model.UserAuditLog{
+		UserId: event.UserId,
+		Event:  event.Event,
+		Ip:     event.Ip,
+		Detail: string(detail),
+	}
+	err := dao.NewAuditLogDao(as.ctx).CreateAuditLog(auditLog)
+	if err != nil {
+		logger.New().Error(as.ctx, "CreateAuditLogErr", "err", err, "event", event)
+		return
+	}
+	logger.New().Info(as.ctx, "user audit event", "event", event)
+}
@@ -0,0 +1,72 @@
+package domainservice
+
+import (
+	"context"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/logic/do"
+	"strings"
+)
+
+// AuthDomainSvc 认证请求携带的Token, 认证中间件和网关转发认证使用同一套认证方式
+type AuthDomainSvc struct {
+	ctx context.Context
+}
+
+func NewAuthDomainSvc(ctx context.Context) *AuthDomainSvc {
+	return &AuthDomainSvc{ctx: ctx}
+}
+
+// tokenStrategy 一种Token的认证方式, match 只根据格式判断Token是否属于这种认证方式
+type tokenStrategy struct {
+	match        func(token string) bool
+	authenticate func(ctx context.Context, token string) (*do.Principal, error)
+}
+
+var tokenStrategies = []tokenStrategy{
+	{
+		match:        func(token string) bool { return strings.HasPrefix(token, enum.ApiKeyPrefix) },
+		authenticate: authApiKey,
+	},
+	{
+		match:        func(token string) bool { return len(token) == 40 }, // 我们生成的token长度为40
+		authenticate: authAccessToken,
+	},
+}
+
+// Authenticate 按Token的格式选择认证方式, 支持用户登录的AccessToken和机器客户端使用的API Key
+// Token无效时返回nil, 认证时服务出错返回error
+func (as *AuthDomainSvc) Authenticate(token string) (*do.Principal, error) {
+	for _, strategy := range tokenStrategies {
+		if strategy.match(token) {
+			return strategy.authenticate(as.ctx, token)
+		}
+	}
+	return nil, nil
+}
+
+func authAccessToken(ctx context.Context, token string) (*do.Principal, error) {
+	tokenVerify, err := NewUserDomainSvc(ctx).VerifyAccessToken(token)
+	if err != nil || !tokenVerify.Approved {
+		return nil, err
+	}
+	return &do.Principal{
+		Type:      enum.PrincipalTypeUser,
+		UserId:    tokenVerify.UserId,
+		SessionId: tokenVerify.SessionId,
+		Platform:  tokenVerify.Platform,
+		Scope:     tokenVerify.Scope,
+	}, nil
+}
+
+func authApiKey(ctx context.Context, key string) (*do.Principal, error) {
+	apiKey, err := NewApiKeyDomainSvc(ctx).Verify(key)
+	if err != nil || apiKey == nil {
+		return nil, err
+	}
+	return &do.Principal{
+		Type:     enum.PrincipalTypeApiKey,
+		UserId:   apiKey.UserId,
+		ApiKeyId: apiKey.Id,
+		Scope:    apiKey.Scope,
+	}, nil
+}
@@ -0,0 +1,179 @@
+package domainservice
+
+import (
+	"context"
+	"errors"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/cache"
+	"github.com/ljinf/user_auth/logic/do"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	deviceCodeLength = 40
+	userCodeLength   = 8
+	// user_code 只使用辅音字母, 避免组成单词以及混淆 0/O、1/I, 参见 RFC 8628 6.1
+	userCodeCharset util.Charset = "BCDFGHJKLMNPQRSTVWXZ"
+	// 设备轮询过快时每次增加的轮询间隔, RFC 8628 3.5 规定为5秒
+	deviceSlowDownStep = 5 * time.Second
+	// 生成的 user_code 与未过期的重复时最多重试的次数
+	userCodeMaxRetries = 5
+)
+
+// DeviceAuthDomainSvc OAuth 2.0 设备授权(RFC 8628)
+// 设备申请 device_code 和 user_code, 用户在已登录的页面上输入 user_code 授权, 设备轮询拿到Token
+type DeviceAuthDomainSvc struct {
+	ctx context.Context
+}
+
+func NewDeviceAuthDomainSvc(ctx context.Context) *DeviceAuthDomainSvc {
+	return &DeviceAuthDomainSvc{ctx: ctx}
+}
+
+// RequestCode 设备申请授权, 必须申请客户端允许范围内的scope
+// 设备登录得到的是受限会话, scope为空的会话是完整的登录会话, 所以不允许不申请scope
+func (ds *DeviceAuthDomainSvc) RequestCode(clientId, scope string) (*do.DeviceCode, error) {
+	client := findDeviceClient(clientId)
+	if client == nil {
+		return nil, errcode.ErrOAuthInvalidClient
+	}
+	scope, ok := narrowScope(scope, client.Scopes)
+	if !ok || scope == "" {
+		return nil, errcode.ErrOAuthInvalidScope
+	}
+	deviceCode, err := util.SecureRandString(deviceCodeLength, util.Alphanumeric)
+	if err != nil {
+		err = errcode.Wrap("生成device_code时发生错误", err)
+		return nil, err
+	}
+	authConf := config.DeviceAuth
+	var userCode string
+	for i := 0; i < userCodeMaxRetries; i++ {
+		userCode, err = util.SecureRandString(userCodeLength, userCodeCharset)
+		if err != nil {
+			err = errcode.Wrap("生成user_code时发生错误", err)
+			return nil, err
+		}
+		auth := &do.DeviceAuthorization{ClientId: clientId, Scope: scope, UserCode: userCode}
+		ok, err = cache.SetDeviceAuthorization(ds.ctx, util.Sha256Hex(deviceCode), auth, authConf.Interval, authConf.TTL)
+		if err != nil {
+			err = errcode.Wrap("设置设备授权缓存时发生错误", err)
+			return nil, err
+		}
+		if ok {
+			displayCode := userCode[:4] + "-" + userCode[4:]
+			return &do.DeviceCode{
+				DeviceCode:              deviceCode,
+				UserCode:                displayCode,
+				VerificationUri:         authConf.VerificationUri,
+				VerificationUriComplete: authConf.VerificationUri + "?user_code=" + url.QueryEscape(displayCode),
+				ExpiresIn:               int64(authConf.TTL.Seconds()),
+				Interval:                int64(authConf.Interval.Seconds()),
+			}, nil
+		}
+	}
+	return nil, errcode.Wrap("生成user_code时发生错误", errors.New("user_code conflicts too many times"))
+}
+
+// GetByUserCode 用户输入 user_code 后查询待授权的请求, 用于在授权页面展示申请授权的客户端
+func (ds *DeviceAuthDomainSvc) GetByUserCode(userCode string) (*do.DeviceAuthorization, *config.DeviceClient, error) {
+	auth, _, err := cache.GetDeviceAuthorizationByUserCode(ds.ctx, normalizeUserCode(userCode))
+	if err != nil {
+		err = errcode.Wrap("获取设备授权缓存时发生错误", err)
+		return nil, nil, err
+	}
+	if auth == nil || auth.Status != enum.DeviceAuthStatusPending {
+		return nil, nil, errcode.ErrDeviceUserCodeInvalid
+	}
+	return auth, findDeviceClient(auth.ClientId), nil
+}
+
+// Decide 已登录用户同意或拒绝设备的授权请求
+func (ds *DeviceAuthDomainSvc) Decide(userCode string, userId int64, approve bool) error {
+	_, deviceCodeHash, err := cache.GetDeviceAuthorizationByUserCode(ds.ctx, normalizeUserCode(userCode))
+	if err != nil {
+		err = errcode.Wrap("获取设备授权缓存时发生错误", err)
+		return err
+	}
+	if deviceCodeHash == "" {
+		return errcode.ErrDeviceUserCodeInvalid
+	}
+	status := enum.DeviceAuthStatusDenied
+	if approve {
+		status = enum.DeviceAuthStatusApproved
+	}
+	ok, err := cache.DecideDeviceAuthorization(ds.ctx, deviceCodeHash, status, userId)
+	if err != nil {
+		err = errcode.Wrap("变更设备授权状态时发生错误", err)
+		return err
+	}
+	if !ok {
+		return errcode.ErrDeviceUserCodeInvalid
+	}
+	return nil
+}
+
+// PollToken 设备轮询授权结果, 用户同意授权后返回授权的用户、scope以及客户端的信息
+// 未授权时按 RFC 8628 3.5 返回 authorization_pending、slow_down、access_denied 或 expired_token
+func (ds *DeviceAuthDomainSvc) PollToken(deviceCode, clientId string) (*do.DeviceAuthorization, *config.DeviceClient, error) {
+	client := findDeviceClient(clientId)
+	if client == nil {
+		return nil, nil, errcode.ErrOAuthInvalidClient
+	}
+	result, userId, scope, err := cache.PollDeviceAuthorization(ds.ctx, util.Sha256Hex(deviceCode), clientId, deviceSlowDownStep)
+	if err != nil {
+		err = errcode.Wrap("查询设备授权结果时发生错误", err)
+		return nil, nil, err
+	}
+	switch result {
+	case enum.DeviceAuthStatusApproved:
+		auth := &do.DeviceAuthorization{ClientId: clientId, Scope: scope, Status: result, UserId: userId}
+		return auth, client, nil
+	case enum.DeviceAuthStatusPending:
+		return nil, nil, errcode.ErrDeviceAuthPending
+	case enum.DeviceAuthStatusDenied:
+		return nil, nil, errcode.ErrDeviceAccessDenied
+	case "slow_down":
+		return nil, nil, errcode.ErrDeviceSlowDown
+	case "invalid_client":
+		// device_code 不是这个客户端申请的
+		return nil, nil, errcode.ErrOAuthInvalidGrant
+	default:
+		return nil, nil, errcode.ErrDeviceCodeExpired
+	}
+}
+
+func findDeviceClient(clientId string) *config.DeviceClient {
+	for i := range config.DeviceAuth.Clients {
+		if config.DeviceAuth.Clients[i].ClientId == clientId {
+			return &config.DeviceAuth.Clients[i]
+		}
+	}
+	return nil
+}
+
+// narrowScope 校验申请的scope(空格分隔)都在允许的范围内, 申请的scope为空时返回空, 不授予任何scope
+func narrowScope(requested string, allowed []string) (string, bool) {
+	allowedSet := make(map[string]struct{}, len(allowed))
+	for _, s := range allowed {
+		allowedSet[s] = struct{}{}
+	}
+	scopes := strings.Fields(requested)
+	for _, s := range scopes {
+		if _, ok := allowedSet[s]; !ok {
+			return "", false
+		}
+	}
+	return strings.Join(scopes, " "), true
+}
+
+// normalizeUserCode 用户输入的 user_code 忽略大小写和分隔符
+func normalizeUserCode(userCode string) string {
+	userCode = strings.ToUpper(userCode)
+	return strings.NewReplacer("-", "", " ", "").Replace(userCode)
+}
@@ -0,0 +1,28 @@
+package domainservice
+
+import "testing"
+
+func TestNarrowScope(t *testing.T) {
+	allowed := []string{"user.read", "user.write"}
+	tests := []struct {
+		name      string
+		requested string
+		wantScope string
+		wantOk    bool
+	}{
+		{name: "empty grants nothing", requested: "", wantScope: "", wantOk: true},
+		{name: "single", requested: "user.read", wantScope: "user.read", wantOk: true},
+		{name: "all", requested: "user.read user.write", wantScope: "user.read user.write", wantOk: true},
+		{name: "extra spaces", requested: "  user.read   user.write ", wantScope: "user.read user.write", wantOk: true},
+		{name: "not allowed", requested: "user.read admin", wantOk: false},
+		{name: "prefix is not a match", requested: "user", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope, ok := narrowScope(tt.requested, allowed)
+			if scope != tt.wantScope || ok != tt.wantOk {
+				t.Errorf("narrowScope(%q) = %q, %v, want %q, %v", tt.requested, scope, ok, tt.wantScope, tt.wantOk)
+			}
+		})
+	}
+}
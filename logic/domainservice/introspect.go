@@ -0,0 +1,52 @@
+package domainservice
+
+import (
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/dal/cache"
+	"github.com/ljinf/user_auth/logic/do"
+)
+
+// IntrospectToken 查询Token的详细信息, 支持用户登录的AccessToken和Token交换得到的Token
+// 交换得到的Token只对它的目标服务 audience 有效, 并且用户的会话被吊销后随之失效
+func (us *UserDomainSvc) IntrospectToken(token, audience string) (*do.TokenIntrospection, error) {
+	session, err := us.sessions.GetAccessToken(us.ctx, token)
+	if err != nil {
+		return nil, errcode.Wrap("GetAccessTokenErr", err)
+	}
+	if session.UserId != 0 {
+		return &do.TokenIntrospection{
+			Active:    true,
+			TokenType: enum.IntrospectTokenTypeAccess,
+			UserId:    session.UserId,
+			SessionId: session.SessionId,
+			Platform:  session.Platform,
+			Scope:     session.Scope,
+		}, nil
+	}
+
+	exchanged, _, err := cache.GetExchangedToken(us.ctx, token)
+	if err != nil {
+		return nil, errcode.Wrap("GetExchangedTokenErr", err)
+	}
+	if exchanged == nil || exchanged.Audience != audience {
+		return &do.TokenIntrospection{Active: false}, nil
+	}
+	userSession, err := us.sessions.GetUserPlatformSession(us.ctx, exchanged.UserId, exchanged.Platform)
+	if err != nil {
+		return nil, errcode.Wrap("GetUserPlatformSessionErr", err)
+	}
+	if userSession == nil || userSession.SessionId != exchanged.SessionId {
+		return &do.TokenIntrospection{Active: false}, nil
+	}
+	return &do.TokenIntrospection{
+		Active:    true,
+		TokenType: enum.IntrospectTokenTypeExchanged,
+		UserId:    exchanged.UserId,
+		SessionId: exchanged.SessionId,
+		Platform:  exchanged.Platform,
+		Scope:     exchanged.Scope,
+		Audience:  exchanged.Audience,
+		Actor:     exchanged.Actor,
+	}, nil
+}
@@ -0,0 +1,137 @@
+package domainservice
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/cache"
+	"github.com/ljinf/user_auth/logic/do"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	magicLinkNonceLength  = 32
+	magicLinkDeviceLength = 32
+)
+
+// MagicLinkDomainSvc H5 邮件一键登录链接
+// 链接中的Token格式为 nonce.过期时间戳.签名, 签名和过期时间在查缓存之前校验, 缓存保证链接只能使用一次
+type MagicLinkDomainSvc struct {
+	ctx context.Context
+}
+
+func NewMagicLinkDomainSvc(ctx context.Context) *MagicLinkDomainSvc {
+	return &MagicLinkDomainSvc{ctx: ctx}
+}
+
+// Send 向邮箱发送登录链接, 开启设备绑定时返回写入浏览器Cookie的随机值
+// 邮箱未注册或者发送失败时同样返回成功, 查询账号和发送在后台完成, 避免接口被用来探测邮箱是否已注册
+func (ms *MagicLinkDomainSvc) Send(email, locale string) (deviceBinding string, err error) {
+	linkConf := config.MagicLink
+	ok, err := cache.LockVerifyCodeResend(ms.ctx, enum.VerifyCodeSceneMagicLink, email, linkConf.ResendCooldown)
+	if err != nil {
+		err = errcode.Wrap("设置登录链接发送冷却期时发生错误", err)
+		return "", err
+	}
+	if !ok {
+		return "", errcode.ErrVerifyCodeSendTooFrequent
+	}
+	if linkConf.BindDevice {
+		deviceBinding, err = util.SecureRandString(magicLinkDeviceLength, util.Alphanumeric)
+		if err != nil {
+			err = errcode.Wrap("生成设备绑定值时发生错误", err)
+			return "", err
+		}
+	}
+
+	// 查询账号、生成链接和发送都在后台完成, 邮箱注册与否接口的响应时间相同
+	go NewMagicLinkDomainSvc(util.DetachTraceContext(ms.ctx)).sendLink(email, locale, deviceBinding)
+	return deviceBinding, nil
+}
+
+// sendLink 在后台查询账号并发送登录链接, 出错时只记录日志
+func (ms *MagicLinkDomainSvc) sendLink(email, locale, deviceBinding string) {
+	log := logger.New()
+	linkConf := config.MagicLink
+	user, err := NewUserDomainSvc(ms.ctx).GetUserBaseInfoByEmail(email)
+	if err != nil {
+		log.Error(ms.ctx, "GetMagicLinkUserErr", "err", err, "email", email)
+		return
+	}
+	if user.ID == 0 || user.IsBlocked == enum.UserBlockStateBlocked {
+		log.Info(ms.ctx, "magic link requested for invalid account", "email", email)
+		return
+	}
+
+	nonce, err := util.SecureRandString(magicLinkNonceLength, util.Alphanumeric)
+	if err != nil {
+		log.Error(ms.ctx, "GenMagicLinkNonceErr", "err", err, "userId", user.ID)
+		return
+	}
+	link := &do.MagicLink{UserId: user.ID}
+	if deviceBinding != "" {
+		link.DeviceHash = util.Sha256Hex(deviceBinding)
+	}
+	if err = cache.SetMagicLink(ms.ctx, nonce, link, linkConf.TTL); err != nil {
+		log.Error(ms.ctx, "SetMagicLinkErr", "err", err, "userId", user.ID)
+		return
+	}
+
+	token := signMagicLinkToken(nonce, time.Now().Add(linkConf.TTL).Unix())
+	err = NewNotificationDomainSvc(ms.ctx).SendMail(email, "magic_link", locale, map[string]interface{}{
+		"Link":    linkConf.Url + "?token=" + url.QueryEscape(token),
+		"Minutes": int(linkConf.TTL.Minutes()),
+	})
+	if err != nil {
+		log.Error(ms.ctx, "SendMagicLinkErr", "err", err, "userId", user.ID)
+	}
+}
+
+// Consume 校验并使用登录链接, 返回链接对应的用户ID
+// deviceBinding 为浏览器Cookie中的设备绑定值, 链接绑定了设备时必须一致
+func (ms *MagicLinkDomainSvc) Consume(token, deviceBinding string) (int64, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, errcode.ErrMagicLinkInvalid
+	}
+	nonce := parts[0]
+	expireAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, errcode.ErrMagicLinkInvalid
+	}
+	if subtle.ConstantTimeCompare([]byte(signMagicLinkToken(nonce, expireAt)), []byte(token)) != 1 {
+		return 0, errcode.ErrMagicLinkInvalid
+	}
+	if time.Now().Unix() > expireAt {
+		return 0, errcode.ErrMagicLinkInvalid
+	}
+
+	link, err := cache.ConsumeMagicLink(ms.ctx, nonce)
+	if err != nil {
+		err = errcode.Wrap("获取登录链接缓存时发生错误", err)
+		return 0, err
+	}
+	if link == nil {
+		return 0, errcode.ErrMagicLinkInvalid
+	}
+	if link.DeviceHash != "" &&
+		subtle.ConstantTimeCompare([]byte(link.DeviceHash), []byte(util.Sha256Hex(deviceBinding))) != 1 {
+		// 链接在其他浏览器中被打开, 链接已经被消费掉, 需要重新申请
+		logger.New().Warn(ms.ctx, "magic link used on another device", "userId", link.UserId)
+		return 0, errcode.ErrMagicLinkInvalid
+	}
+	return link.UserId, nil
+}
+
+func signMagicLinkToken(nonce string, expireAt int64) string {
+	payload := fmt.Sprintf("%s.%d", nonce, expireAt)
+	return payload + "." + util.HmacSha256Hex(config.MagicLink.SignKey, payload)
+}
@@ -0,0 +1,65 @@
+package domainservice
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/cache"
+	"github.com/ljinf/user_auth/dal/dao"
+	"github.com/ljinf/user_auth/dal/model"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 测试使用开发环境的配置, 数据库换成sqlite内存库, Redis换成miniredis
+var testRedis *miniredis.Miniredis
+
+func TestMain(m *testing.M) {
+	if err := config.Load("../../config/application.dev.yaml"); err != nil {
+		panic(err)
+	}
+	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		panic(err)
+	}
+	if err = db.AutoMigrate(&model.User{}, &model.UserLoginHistory{}, &model.UserPasswordHistory{}); err != nil {
+		panic(err)
+	}
+	dao.SetDB(db, db)
+
+	testRedis, err = miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	client := redis.NewClient(&redis.Options{Addr: testRedis.Addr()})
+	cache.SetRedis(client)
+	cache.SetSessionStore(cache.NewRedisSessionStore(client))
+
+	code := m.Run()
+	testRedis.Close()
+	os.Exit(code)
+}
+
+// resetTestData 清空Redis和数据库, 每个用例之间互不影响
+func resetTestData(t *testing.T) {
+	t.Helper()
+	testRedis.FlushAll()
+	for _, table := range []string{"users", "user_login_histories", "user_password_histories"} {
+		if err := dao.DBMaster().Exec("DELETE FROM " + table).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func createTestUser(t *testing.T, user *model.User) *model.User {
+	t.Helper()
+	if err := dao.DBMaster().WithContext(context.Background()).Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
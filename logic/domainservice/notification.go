@@ -0,0 +1,39 @@
+package domainservice
+
+import (
+	"context"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/library"
+)
+
+// NotificationDomainSvc 向用户发送邮件等通知
+type NotificationDomainSvc struct {
+	ctx context.Context
+}
+
+func NewNotificationDomainSvc(ctx context.Context) *NotificationDomainSvc {
+	return &NotificationDomainSvc{ctx: ctx}
+}
+
+// SendMail 使用指定语言的邮件模板渲染并发送邮件, 模板中可以直接使用 AppName
+func (ns *NotificationDomainSvc) SendMail(to, templateName, locale string, data map[string]interface{}) error {
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	data["AppName"] = config.App.Name
+	mail, err := library.RenderMail(templateName, locale, to, data)
+	if err != nil {
+		err = errcode.Wrap("渲染邮件模板时发生错误", err)
+		return err
+	}
+	mailSender, err := library.NewMailSender()
+	if err == nil {
+		err = mailSender.Send(ns.ctx, mail)
+	}
+	if err != nil {
+		err = errcode.Wrap("发送邮件时发生错误", err)
+		return err
+	}
+	return nil
+}
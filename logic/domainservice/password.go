@@ -0,0 +1,303 @@
+package domainservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/cache"
+	"github.com/ljinf/user_auth/dal/cache/lock"
+	"github.com/ljinf/user_auth/dal/dao"
+	"github.com/ljinf/user_auth/dal/model"
+	"github.com/ljinf/user_auth/library"
+	"github.com/ljinf/user_auth/logic/do"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// passwordResetTokenRandLength 重置密码Token中随机部分的长度
+const passwordResetTokenRandLength = 40
+
+// PasswordDomainSvc 用户密码的设置、找回
+type PasswordDomainSvc struct {
+	ctx context.Context
+}
+
+func NewPasswordDomainSvc(ctx context.Context) *PasswordDomainSvc {
+	return &PasswordDomainSvc{ctx: ctx}
+}
+
+// SendResetToken 向账号(邮箱或手机号)发送重置密码的链接
+// 账号不存在或者发送失败时同样返回成功, 只记录日志, 避免接口被用来探测账号是否存在
+func (ps *PasswordDomainSvc) SendResetToken(account, locale string) error {
+	// 发送频率限制放在查询账号之前, 存在与否的账号表现一致
+	ok, err := cache.LockVerifyCodeResend(ps.ctx, enum.VerifyCodeScenePasswordReset, account, config.PasswordReset.ResendCooldown)
+	if err != nil {
+		err = errcode.Wrap("设置重置密码发送冷却期时发生错误", err)
+		return err
+	}
+	if !ok {
+		return errcode.ErrVerifyCodeSendTooFrequent
+	}
+	// 查询账号、生成Token和发送都在后台完成, 账号存在与否接口的响应时间相同
+	go NewPasswordDomainSvc(util.DetachTraceContext(ps.ctx)).sendResetToken(account, locale)
+	return nil
+}
+
+// sendResetToken 账号存在且未被封禁时生成重置密码Token并发送, 出错时只记录日志
+func (ps *PasswordDomainSvc) sendResetToken(account, locale string) {
+	log := logger.New()
+	isEmail := strings.Contains(account, "@")
+	userDomainSvc := NewUserDomainSvc(ps.ctx)
+	var (
+		user *do.UserBaseInfo
+		err  error
+	)
+	if isEmail {
+		user, err = userDomainSvc.GetUserBaseInfoByEmail(account)
+	} else {
+		user, err = userDomainSvc.GetUserBaseInfoByPhone(account)
+	}
+	if err != nil {
+		log.Error(ps.ctx, "GetPasswordResetUserErr", "err", err, "account", account)
+		return
+	}
+	if user.ID == 0 || user.IsBlocked == enum.UserBlockStateBlocked {
+		log.Info(ps.ctx, "password reset requested for invalid account", "account", account)
+		return
+	}
+
+	token, err := util.GenUserBoundToken(user.ID, passwordResetTokenRandLength)
+	if err != nil {
+		log.Error(ps.ctx, "GenPasswordResetTokenErr", "err", err, "userId", user.ID)
+		return
+	}
+	err = cache.SetPasswordResetToken(ps.ctx, user.ID, util.Sha256Hex(token), config.PasswordReset.TokenTTL)
+	if err != nil {
+		log.Error(ps.ctx, "SetPasswordResetTokenErr", "err", err, "userId", user.ID)
+		return
+	}
+
+	link := config.PasswordReset.Url + "?token=" + url.QueryEscape(token)
+	minutes := int(config.PasswordReset.TokenTTL.Minutes())
+	if isEmail {
+		err = NewNotificationDomainSvc(ps.ctx).SendMail(account, "password_reset", locale, map[string]interface{}{
+			"Link":    link,
+			"Minutes": minutes,
+		})
+	} else {
+		content := fmt.Sprintf("【%s】您正在重置密码, 请在%d分钟内打开链接设置新密码: %s 如非本人操作请忽略。",
+			config.Sms.SignName, minutes, link)
+		var smsSender library.SmsSender
+		if smsSender, err = library.NewSmsSender(); err == nil {
+			err = smsSender.Send(ps.ctx, account, content)
+		}
+	}
+	if err != nil {
+		log.Error(ps.ctx, "SendPasswordResetTokenErr", "err", err, "userId", user.ID)
+	}
+}
+
+// ResetPassword 使用重置密码Token设置新密码, 成功后用户所有平台的登录状态都会失效
+// 新密码不符合密码策略时Token不会失效, 用户可以换个密码重试
+func (ps *PasswordDomainSvc) ResetPassword(token, newPassword, ip string) error {
+	tokenHash := util.Sha256Hex(token)
+	tokenUserId := util.ParseUserIdFromBoundToken(token)
+	if tokenUserId == 0 {
+		return errcode.ErrPasswordResetTokenInvalid
+	}
+	userId, err := cache.GetPasswordResetToken(ps.ctx, tokenUserId, tokenHash)
+	if err != nil {
+		err = errcode.Wrap("获取重置密码Token缓存时发生错误", err)
+		return err
+	}
+	if userId == 0 {
+		return errcode.ErrPasswordResetTokenInvalid
+	}
+	user, err := dao.NewUserDao(ps.ctx).FindUserById(userId)
+	if err != nil {
+		err = errcode.Wrap("查询用户信息时发生错误", err)
+		return err
+	}
+	if user == nil || user.IsBlocked == enum.UserBlockStateBlocked {
+		return errcode.ErrPasswordResetTokenInvalid
+	}
+	if err = ps.checkPolicy(user, newPassword); err != nil {
+		return err
+	}
+	// 密码更新成功后才让Token失效, 更新失败时用户可以用同一个链接重试
+	// 加锁保证并发的请求只有一个能用Token设置密码
+	resetLock, err := lock.New(cache.Redis()).Obtain(ps.ctx, fmt.Sprintf(enum.REDIS_KEY_PASSWORD_RESET_LOCK, userId), 10*time.Second)
+	if errors.Is(err, lock.ErrNotObtained) {
+		return errcode.ErrTooManyRequests
+	}
+	if err != nil {
+		err = errcode.Wrap("重置密码时设置Redis锁发生错误", err)
+		return err
+	}
+	defer resetLock.Release(ps.ctx)
+	// 拿到锁之前Token可能已经被其他请求用掉
+	lockedUserId, err := cache.GetPasswordResetToken(ps.ctx, userId, tokenHash)
+	if err != nil {
+		err = errcode.Wrap("获取重置密码Token缓存时发生错误", err)
+		return err
+	}
+	if lockedUserId != userId {
+		return errcode.ErrPasswordResetTokenInvalid
+	}
+	if err = ps.updatePassword(user, newPassword); err != nil {
+		return err
+	}
+	if _, err = cache.ConsumePasswordResetToken(ps.ctx, userId, tokenHash); err != nil {
+		err = errcode.Wrap("删除重置密码Token缓存时发生错误", err)
+		return err
+	}
+	if err = NewUserDomainSvc(ps.ctx).RevokeAllSessions(userId); err != nil {
+		return err
+	}
+	NewAuditDomainSvc(ps.ctx).Record(&do.AuditEvent{
+		UserId: userId,
+		Event:  enum.AuditEventPasswordReset,
+		Ip:     ip,
+	})
+	return nil
+}
+
+// ChangePassword 已登录用户修改密码
+// 用户还没有设置过密码时(比如一直使用验证码登录)不需要校验当前密码
+// logoutOthers 为true时除当前Session外用户其他平台的登录状态都会失效
+func (ps *PasswordDomainSvc) ChangePassword(userId int64, sessionId, currentPassword, newPassword, code string, logoutOthers bool, ip string) error {
+	user, err := dao.NewUserDao(ps.ctx).FindUserById(userId)
+	if err != nil {
+		err = errcode.Wrap("查询用户信息时发生错误", err)
+		return err
+	}
+	if user == nil || user.IsBlocked == enum.UserBlockStateBlocked {
+		return errcode.ErrUserInvalid
+	}
+	if user.Password != "" {
+		if err = ps.checkCurrentPassword(user, currentPassword); err != nil {
+			return err
+		}
+	} else if err = ps.checkSetPasswordCode(user, code); err != nil {
+		// 没有设置过密码的用户只凭登录状态不能设置密码, 避免被盗用的会话给账号加上攻击者知道的密码
+		return err
+	}
+	if err = ps.checkPolicy(user, newPassword); err != nil {
+		return err
+	}
+	if err = ps.updatePassword(user, newPassword); err != nil {
+		return err
+	}
+	if logoutOthers {
+		if err = NewUserDomainSvc(ps.ctx).RevokeOtherSessions(userId, sessionId); err != nil {
+			return err
+		}
+	}
+	NewAuditDomainSvc(ps.ctx).Record(&do.AuditEvent{
+		UserId: userId,
+		Event:  enum.AuditEventPasswordChange,
+		Ip:     ip,
+		Detail: map[string]interface{}{
+			"session_id":    sessionId,
+			"logout_others": logoutOthers,
+		},
+	})
+	return nil
+}
+
+// checkCurrentPassword 校验修改密码时输入的当前密码
+// 输错计入登录失败次数, 达到风控的失败阈值后不再校验, 避免被盗用的会话用来无限次尝试当前密码
+func (ps *PasswordDomainSvc) checkCurrentPassword(user *model.User, currentPassword string) error {
+	riskConf := config.Risk
+	if riskConf.FailureThreshold > 0 {
+		failures, err := cache.GetLoginFailureCount(ps.ctx, user.Id)
+		if err != nil {
+			err = errcode.Wrap("查询登录失败次数时发生错误", err)
+			return err
+		}
+		if failures >= riskConf.FailureThreshold {
+			return errcode.ErrTooManyRequests
+		}
+	}
+	if util.CheckPassword(user.Password, currentPassword) {
+		return nil
+	}
+	if _, err := cache.IncrLoginFailure(ps.ctx, user.Id, riskConf.FailureWindow); err != nil {
+		logger.New().Error(ps.ctx, "IncrLoginFailureErr", "err", err, "userId", user.Id)
+	}
+	return errcode.ErrPasswordIncorrect
+}
+
+// SendSetPasswordCode 向还没有设置过密码的用户发送设置密码的验证码, locale 为邮件模板使用的语言
+func (ps *PasswordDomainSvc) SendSetPasswordCode(userId int64, locale string) error {
+	user, err := dao.NewUserDao(ps.ctx).FindUserById(userId)
+	if err != nil {
+		err = errcode.Wrap("查询用户信息时发生错误", err)
+		return err
+	}
+	if user == nil || user.IsBlocked == enum.UserBlockStateBlocked {
+		return errcode.ErrUserInvalid
+	}
+	verifyCodeDomainSvc := NewVerifyCodeDomainSvc(ps.ctx)
+	switch {
+	case user.Phone != "":
+		return verifyCodeDomainSvc.SendSmsCode(enum.VerifyCodeSceneSetPassword, user.Phone)
+	case user.Email != "":
+		return verifyCodeDomainSvc.SendEmailCode(enum.VerifyCodeSceneSetPassword, user.Email, locale)
+	default:
+		return errcode.ErrContactNotBound
+	}
+}
+
+// checkSetPasswordCode 校验发送到用户手机号或邮箱的设置密码验证码
+func (ps *PasswordDomainSvc) checkSetPasswordCode(user *model.User, code string) error {
+	if code == "" {
+		return errcode.ErrSetPasswordNeedVerifyCode
+	}
+	target := user.Phone
+	if target == "" {
+		target = user.Email
+	}
+	if target == "" {
+		return errcode.ErrContactNotBound
+	}
+	return NewVerifyCodeDomainSvc(ps.ctx).CheckCode(enum.VerifyCodeSceneSetPassword, target, code)
+}
+
+// checkPolicy 按密码策略检查新密码, 未通过的规则作为字段错误返回给客户端
+func (ps *PasswordDomainSvc) checkPolicy(user *model.User, password string) error {
+	violations, err := NewPasswordPolicyDomainSvc(ps.ctx).Check(user, "new_password", password)
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return errcode.ErrPasswordPolicy.WithFields(violations...)
+	}
+	return nil
+}
+
+// updatePassword 更新用户密码, 同时把新密码记入密码历史
+func (ps *PasswordDomainSvc) updatePassword(user *model.User, password string) error {
+	passwordHash, err := util.HashPassword(password)
+	if err != nil {
+		err = errcode.Wrap("生成密码哈希时发生错误", err)
+		return err
+	}
+	err = dao.NewUserDao(ps.ctx).UpdateUserPassword(user.Id, passwordHash)
+	if err != nil {
+		err = errcode.Wrap("更新用户密码时发生错误", err)
+		return err
+	}
+	history := &model.UserPasswordHistory{UserId: user.Id, Password: passwordHash}
+	if err = dao.NewPasswordHistoryDao(ps.ctx).CreatePasswordHistory(history); err != nil {
+		// 密码已经更新成功, 历史记录写入失败只影响防重复使用的检查
+		logger.New().Error(ps.ctx, "CreatePasswordHistoryErr", "err", err, "userId", user.Id)
+	}
+	return nil
+}
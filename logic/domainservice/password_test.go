@@ -0,0 +1,40 @@
+package domainservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/model"
+)
+
+// TestChangePasswordFailureLimit 修改密码时输错当前密码计入失败次数, 达到阈值后正确的密码也被拒绝
+func TestChangePasswordFailureLimit(t *testing.T) {
+	resetTestData(t)
+	currentHash, err := util.HashPassword("Current#123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := createTestUser(t, &model.User{LoginName: "alice", Password: currentHash})
+	svc := NewPasswordDomainSvc(context.Background())
+	change := func(currentPassword string) error {
+		return svc.ChangePassword(user.Id, "session", currentPassword, "Another#123", "", false, "10.0.0.1")
+	}
+
+	for i := int64(0); i < config.Risk.FailureThreshold; i++ {
+		if err = change("Wrong#123"); err != errcode.ErrPasswordIncorrect {
+			t.Fatalf("attempt %d: ChangePassword() err = %v, want %v", i+1, err, errcode.ErrPasswordIncorrect)
+		}
+	}
+	if err = change("Current#123"); err != errcode.ErrTooManyRequests {
+		t.Fatalf("ChangePassword() after %d failures err = %v, want %v", config.Risk.FailureThreshold, err, errcode.ErrTooManyRequests)
+	}
+
+	// 失败窗口过去后可以正常修改
+	testRedis.FastForward(config.Risk.FailureWindow)
+	if err = change("Current#123"); err != nil {
+		t.Fatalf("ChangePassword() after failure window err = %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package domainservice
+
+import (
+	"context"
+	"fmt"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/dao"
+	"github.com/ljinf/user_auth/dal/model"
+	"github.com/ljinf/user_auth/library"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 密码策略中的规则名, 作为 FieldError.Rule 返回给客户端
+const (
+	passwordRuleMinLength     = "min_length"
+	passwordRuleMaxLength     = "max_length"
+	passwordRuleLower         = "lower"
+	passwordRuleUpper         = "upper"
+	passwordRuleDigit         = "digit"
+	passwordRuleSymbol        = "symbol"
+	passwordRuleWithLoginName = "contains_login_name"
+	passwordRuleReused        = "reused"
+	passwordRuleBreached      = "breached"
+)
+
+// bcrypt 只使用密码的前72个字节, 更长的密码超出部分不参与校验, 不管 max_length 如何配置都不允许超过
+const bcryptMaxPasswordBytes = 72
+
+// PasswordPolicyDomainSvc 按配置文件中的密码策略检查用户设置的新密码
+type PasswordPolicyDomainSvc struct {
+	ctx context.Context
+}
+
+func NewPasswordPolicyDomainSvc(ctx context.Context) *PasswordPolicyDomainSvc {
+	return &PasswordPolicyDomainSvc{ctx: ctx}
+}
+
+// Check 检查用户的新密码, 返回所有未通过的规则, field 为响应中的字段名
+// 全部规则都通过时返回nil
+func (ps *PasswordPolicyDomainSvc) Check(user *model.User, field, password string) ([]*errcode.FieldError, error) {
+	policy := config.PasswordPolicy
+	var violations []*errcode.FieldError
+	violate := func(rule, msg string) {
+		violations = append(violations, &errcode.FieldError{Field: field, Rule: rule, Msg: msg})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < policy.MinLength {
+		violate(passwordRuleMinLength, fmt.Sprintf("密码长度不能少于%d位", policy.MinLength))
+	}
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		violate(passwordRuleMaxLength, fmt.Sprintf("密码长度不能超过%d位", policy.MaxLength))
+	} else if len(password) > bcryptMaxPasswordBytes {
+		// 中文等多字节字符按位数没有超长, 按字节数可能超过bcrypt的上限
+		violate(passwordRuleMaxLength, fmt.Sprintf("密码长度不能超过%d个字节", bcryptMaxPasswordBytes))
+	}
+	var hasLower, hasUpper, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+	if policy.RequireLower && !hasLower {
+		violate(passwordRuleLower, "密码必须包含小写字母")
+	}
+	if policy.RequireUpper && !hasUpper {
+		violate(passwordRuleUpper, "密码必须包含大写字母")
+	}
+	if policy.RequireDigit && !hasDigit {
+		violate(passwordRuleDigit, "密码必须包含数字")
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		violate(passwordRuleSymbol, "密码必须包含特殊字符")
+	}
+	if policy.RejectLoginName && user.LoginName != "" &&
+		strings.Contains(strings.ToLower(password), strings.ToLower(user.LoginName)) {
+		violate(passwordRuleWithLoginName, "密码不能包含登录名")
+	}
+
+	// 不管是否开启密码历史, 新密码都不能与当前密码相同
+	if user.Password != "" && util.CheckPassword(user.Password, password) {
+		violate(passwordRuleReused, "新密码不能与当前密码相同")
+	} else {
+		reused, err := ps.isReused(user, password)
+		if err != nil {
+			return nil, err
+		}
+		if reused {
+			violate(passwordRuleReused, fmt.Sprintf("不能使用最近%d次使用过的密码", policy.HistorySize))
+		}
+	}
+	if ps.isBreached(password) {
+		violate(passwordRuleBreached, "该密码已在公开的数据泄露中出现过, 请更换")
+	}
+	return violations, nil
+}
+
+// isReused 新密码是否与最近使用过的密码相同, 没有开启密码历史时不检查
+func (ps *PasswordPolicyDomainSvc) isReused(user *model.User, password string) (bool, error) {
+	historySize := config.PasswordPolicy.HistorySize
+	if historySize <= 0 {
+		return false, nil
+	}
+	histories, err := dao.NewPasswordHistoryDao(ps.ctx).GetRecentPasswordHistories(user.Id, historySize)
+	if err != nil {
+		err = errcode.Wrap("查询用户密码历史时发生错误", err)
+		return false, err
+	}
+	for _, history := range histories {
+		if util.CheckPassword(history.Password, password) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isBreached 新密码是否出现在泄露密码库中, 密码库读取失败时不阻止用户设置密码
+func (ps *PasswordPolicyDomainSvc) isBreached(password string) bool {
+	policy := config.PasswordPolicy
+	if policy.BreachedCorpusDir == "" {
+		return false
+	}
+	count, err := library.NewBreachedPasswordChecker(policy.BreachedCorpusDir).BreachedCount(ps.ctx, password)
+	if err != nil {
+		logger.New().Error(ps.ctx, "BreachedPasswordCheckErr", "err", err)
+		return false
+	}
+	return count > 0 && count >= policy.BreachedMinCount
+}
@@ -0,0 +1,120 @@
+package domainservice
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/dao"
+	"github.com/ljinf/user_auth/dal/model"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	oldPolicy := *config.PasswordPolicy
+	t.Cleanup(func() { *config.PasswordPolicy = oldPolicy })
+	policy := config.PasswordPolicy
+	policy.MinLength = 8
+	policy.MaxLength = 64
+	policy.RequireLower = true
+	policy.RequireUpper = true
+	policy.RequireDigit = true
+	policy.RequireSymbol = true
+	policy.RejectLoginName = true
+	policy.HistorySize = 2
+	policy.BreachedCorpusDir = ""
+
+	currentHash, err := util.HashPassword("Current#123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldHash, err := util.HashPassword("Previous#123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		password  string
+		wantRules []string
+	}{
+		{name: "valid", password: "Valid#Pass1"},
+		{name: "too short", password: "Va#1", wantRules: []string{passwordRuleMinLength}},
+		{name: "too long", password: "Va#1" + strings.Repeat("a", 61), wantRules: []string{passwordRuleMaxLength}},
+		// 按位数没有超过max_length, 按字节数超过了bcrypt的72字节
+		{name: "too many bytes", password: "Va#1" + strings.Repeat("密", 30), wantRules: []string{passwordRuleMaxLength}},
+		{name: "no lower", password: "VALID#PASS1", wantRules: []string{passwordRuleLower}},
+		{name: "no upper", password: "valid#pass1", wantRules: []string{passwordRuleUpper}},
+		{name: "no digit", password: "Valid#Pass", wantRules: []string{passwordRuleDigit}},
+		{name: "no symbol", password: "ValidPass1", wantRules: []string{passwordRuleSymbol}},
+		{name: "multiple rules", password: "valid", wantRules: []string{
+			passwordRuleMinLength, passwordRuleUpper, passwordRuleDigit, passwordRuleSymbol}},
+		{name: "contains login name", password: "My#Alice2024", wantRules: []string{passwordRuleWithLoginName}},
+		{name: "same as current", password: "Current#123", wantRules: []string{passwordRuleReused}},
+		{name: "same as history", password: "Previous#123", wantRules: []string{passwordRuleReused}},
+	}
+
+	resetTestData(t)
+	ctx := context.Background()
+	user := createTestUser(t, &model.User{LoginName: "alice", Password: currentHash})
+	if err = dao.DBMaster().Create(&model.UserPasswordHistory{UserId: user.Id, Password: oldHash}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := NewPasswordPolicyDomainSvc(ctx).Check(user, "password", tt.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rules []string
+			for _, violation := range violations {
+				if violation.Field != "password" {
+					t.Errorf("violation field = %q, want password", violation.Field)
+				}
+				rules = append(rules, violation.Rule)
+			}
+			if !reflect.DeepEqual(rules, tt.wantRules) {
+				t.Errorf("Check() rules = %v, want %v", rules, tt.wantRules)
+			}
+		})
+	}
+}
+
+// TestPasswordPolicyCheckWithoutHistory 没有开启密码历史时新密码仍然不能与当前密码相同
+func TestPasswordPolicyCheckWithoutHistory(t *testing.T) {
+	oldPolicy := *config.PasswordPolicy
+	t.Cleanup(func() { *config.PasswordPolicy = oldPolicy })
+	config.PasswordPolicy.HistorySize = 0
+	config.PasswordPolicy.BreachedCorpusDir = ""
+
+	currentHash, err := util.HashPassword("Current#123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &model.User{Id: 1, Password: currentHash}
+	tests := []struct {
+		name      string
+		password  string
+		wantRules []string
+	}{
+		{name: "same as current", password: "Current#123", wantRules: []string{passwordRuleReused}},
+		{name: "different", password: "Another#123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := NewPasswordPolicyDomainSvc(context.Background()).Check(user, "password", tt.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rules []string
+			for _, violation := range violations {
+				rules = append(rules, violation.Rule)
+			}
+			if !reflect.DeepEqual(rules, tt.wantRules) {
+				t.Errorf("Check() rules = %v, want %v", rules, tt.wantRules)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package domainservice
+
+import (
+	"context"
+	"crypto/subtle"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/cache"
+)
+
+const (
+	qrTicketLength       = 32
+	qrTicketSecretLength = 32
+)
+
+// QrLoginDomainSvc PC扫码登录
+// PC端申请二维码后轮询状态, 已登录的App扫码并确认后, PC端在下一次轮询时拿到Token
+// 状态流转: pending -> scanned -> confirmed, 二维码过期或者Token发放后缓存被删除, 查询时为 expired
+type QrLoginDomainSvc struct {
+	ctx context.Context
+}
+
+func NewQrLoginDomainSvc(ctx context.Context) *QrLoginDomainSvc {
+	return &QrLoginDomainSvc{ctx: ctx}
+}
+
+// CreateTicket 生成二维码, ticket为二维码的内容, secret只返回给PC端用于轮询状态
+func (qs *QrLoginDomainSvc) CreateTicket() (ticket, secret string, err error) {
+	ticket, err = util.SecureRandString(qrTicketLength, util.Alphanumeric)
+	if err != nil {
+		err = errcode.Wrap("生成二维码时发生错误", err)
+		return
+	}
+	secret, err = util.SecureRandString(qrTicketSecretLength, util.Alphanumeric)
+	if err != nil {
+		err = errcode.Wrap("生成二维码时发生错误", err)
+		return
+	}
+	err = cache.SetQrTicket(qs.ctx, ticket, util.Sha256Hex(secret), config.QrLogin.TTL)
+	if err != nil {
+		err = errcode.Wrap("设置二维码缓存时发生错误", err)
+		return
+	}
+	return
+}
+
+// Scan App用户扫描二维码
+func (qs *QrLoginDomainSvc) Scan(ticket string, userId int64) error {
+	return qs.transit(ticket, enum.QrTicketStatusPending, enum.QrTicketStatusScanned, userId, false)
+}
+
+// Confirm App用户确认在PC端登录, 只有扫码的用户才能确认
+func (qs *QrLoginDomainSvc) Confirm(ticket string, userId int64) error {
+	return qs.transit(ticket, enum.QrTicketStatusScanned, enum.QrTicketStatusConfirmed, userId, true)
+}
+
+// Status PC端查询二维码状态, 不会使二维码失效, 状态为 confirmed 时需要通过 Poll 拿到扫码的用户ID
+func (qs *QrLoginDomainSvc) Status(ticket, secret string) (string, error) {
+	qrTicket, err := cache.GetQrTicket(qs.ctx, ticket)
+	if err != nil {
+		err = errcode.Wrap("获取二维码缓存时发生错误", err)
+		return "", err
+	}
+	if qrTicket == nil {
+		return enum.QrTicketStatusExpired, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(qrTicket.SecretHash), []byte(util.Sha256Hex(secret))) != 1 {
+		return "", errcode.ErrQrTicketInvalid
+	}
+	return qrTicket.Status, nil
+}
+
+// Poll PC端查询二维码状态, 状态为 confirmed 时同时返回扫码的用户ID且二维码立即失效
+func (qs *QrLoginDomainSvc) Poll(ticket, secret string) (status string, userId int64, err error) {
+	status, err = qs.Status(ticket, secret)
+	if err != nil || status != enum.QrTicketStatusConfirmed {
+		return
+	}
+	userId, err = cache.ConsumeQrTicket(qs.ctx, ticket)
+	if err != nil {
+		err = errcode.Wrap("删除二维码缓存时发生错误", err)
+		return
+	}
+	if userId == 0 {
+		// 并发的轮询请求已经拿走了Token
+		status = enum.QrTicketStatusExpired
+		return
+	}
+	status = enum.QrTicketStatusConfirmed
+	return
+}
+
+func (qs *QrLoginDomainSvc) transit(ticket, fromStatus, toStatus string, userId int64, checkUser bool) error {
+	ok, err := cache.TransitQrTicket(qs.ctx, ticket, fromStatus, toStatus, userId, checkUser)
+	if err != nil {
+		err = errcode.Wrap("变更二维码状态时发生错误", err)
+		return err
+	}
+	if !ok {
+		return errcode.ErrQrTicketInvalid
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package domainservice
+
+import (
+	"context"
+	"crypto/hmac"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/cache"
+	"strconv"
+	"time"
+)
+
+// RequestSignDomainSvc 校验内部服务间调用的请求签名
+type RequestSignDomainSvc struct {
+	ctx context.Context
+}
+
+func NewRequestSignDomainSvc(ctx context.Context) *RequestSignDomainSvc {
+	return &RequestSignDomainSvc{ctx: ctx}
+}
+
+// SignedRequest 签名请求中参与校验的内容
+type SignedRequest struct {
+	KeyId      string
+	Timestamp  string
+	Nonce      string
+	Signature  string
+	Method     string
+	RequestUri string
+	Body       []byte
+}
+
+// Verify 校验请求签名, 通过后返回调用方使用的密钥
+// 先校验签名再记录随机数, 避免伪造的请求占用合法调用方的随机数
+func (rs *RequestSignDomainSvc) Verify(req *SignedRequest) (*config.SignKey, error) {
+	signKey := findSignKey(req.KeyId)
+	if signKey == nil || req.Nonce == "" || len(req.Nonce) > 64 {
+		return nil, errcode.ErrSignatureInvalid
+	}
+	timestamp, err := strconv.ParseInt(req.Timestamp, 10, 64)
+	if err != nil {
+		return nil, errcode.ErrSignatureInvalid
+	}
+	skew := time.Since(time.Unix(timestamp, 0))
+	if skew > config.RequestSign.MaxSkew || skew < -config.RequestSign.MaxSkew {
+		return nil, errcode.ErrSignatureExpired
+	}
+	expected := util.SignRequest(signKey.Secret, req.Method, req.RequestUri, req.Body, timestamp, req.Nonce)
+	if !hmac.Equal([]byte(expected), []byte(req.Signature)) {
+		return nil, errcode.ErrSignatureInvalid
+	}
+	// 时间戳在前后 MaxSkew 内都有效, 随机数需要保留两倍的时间
+	fresh, err := cache.UseRequestNonce(rs.ctx, signKey.KeyId, req.Nonce, 2*config.RequestSign.MaxSkew)
+	if err != nil {
+		return nil, errcode.Wrap("UseRequestNonceErr", err)
+	}
+	if !fresh {
+		logger.New().Warn(rs.ctx, "signed request replayed", "keyId", signKey.KeyId, "nonce", req.Nonce)
+		return nil, errcode.ErrRequestReplayed
+	}
+	return signKey, nil
+}
+
+func findSignKey(keyId string) *config.SignKey {
+	for i := range config.RequestSign.Keys {
+		if config.RequestSign.Keys[i].KeyId == keyId {
+			return &config.RequestSign.Keys[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package domainservice
+
+import (
+	"context"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/common/util/localcache"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/cache"
+	"github.com/ljinf/user_auth/dal/dao"
+	"github.com/ljinf/user_auth/dal/model"
+	"github.com/ljinf/user_auth/library"
+	"github.com/ljinf/user_auth/logic/do"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IP归属地在本地缓存一段时间, 避免每次登录都请求外部的归属地服务
+var (
+	ipLocationCache     *localcache.Cache[string, *library.WhoisIpDetail]
+	ipLocationCacheOnce sync.Once
+)
+
+func getIpLocationCache() *localcache.Cache[string, *library.WhoisIpDetail] {
+	ipLocationCacheOnce.Do(func() {
+		ipLocationCache = localcache.New[string, *library.WhoisIpDetail](config.Risk.GeoCacheEntries)
+	})
+	return ipLocationCache
+}
+
+// RiskDomainSvc 登录风控引擎, 根据用户的登录历史对每次登录尝试进行风险评分
+type RiskDomainSvc struct {
+	ctx context.Context
+}
+
+func NewRiskDomainSvc(ctx context.Context) *RiskDomainSvc {
+	return &RiskDomainSvc{ctx: ctx}
+}
+
+// EvaluateLogin 对登录尝试进行风险评分
+// 风控依赖的存储或者IP归属地服务出错时, 对应的风险项不计分, 保证风控故障时用户仍然可以登录
+func (rs *RiskDomainSvc) EvaluateLogin(attempt *do.LoginAttempt) *do.RiskAssessment {
+	log := logger.New()
+	riskConf := config.Risk
+	assessment := &do.RiskAssessment{Action: enum.RiskActionAllow}
+	hit := func(reason string, weight int) {
+		assessment.Score += weight
+		assessment.Reasons = append(assessment.Reasons, reason)
+	}
+
+	if rs.isBadIp(attempt.Ip) {
+		hit(enum.RiskReasonBadIp, riskConf.Weights.BadIp)
+	}
+	failures, err := cache.GetLoginFailureCount(rs.ctx, attempt.UserId)
+	if err != nil {
+		log.Error(rs.ctx, "GetLoginFailureCountErr", "err", err)
+	}
+	if riskConf.FailureThreshold > 0 && failures >= riskConf.FailureThreshold {
+		hit(enum.RiskReasonTooManyFailures, riskConf.Weights.TooManyFailures)
+	}
+
+	rs.fillIpLocation(attempt.Ip, assessment)
+	historyDao := dao.NewLoginHistoryDao(rs.ctx)
+	lastLogin, err := historyDao.GetLastSuccessLogin(attempt.UserId)
+	if err != nil {
+		log.Error(rs.ctx, "GetLastSuccessLoginErr", "err", err)
+	}
+	// 首次登录的用户没有历史可以参照, 不判断新国家、新设备和异地登录
+	if lastLogin != nil {
+		if assessment.CountryCode != "" {
+			visited, err := historyDao.HasSuccessLoginFromCountry(attempt.UserId, assessment.CountryCode)
+			if err != nil {
+				log.Error(rs.ctx, "HasSuccessLoginFromCountryErr", "err", err)
+			} else if !visited {
+				hit(enum.RiskReasonNewCountry, riskConf.Weights.NewCountry)
+			}
+		}
+		if attempt.DeviceId != "" {
+			used, err := historyDao.HasSuccessLoginWithDevice(attempt.UserId, attempt.DeviceId)
+			if err != nil {
+				log.Error(rs.ctx, "HasSuccessLoginWithDeviceErr", "err", err)
+			} else if !used {
+				hit(enum.RiskReasonNewDevice, riskConf.Weights.NewDevice)
+			}
+		}
+		if rs.isImpossibleTravel(lastLogin, assessment) {
+			hit(enum.RiskReasonImpossibleTravel, riskConf.Weights.ImpossibleTravel)
+		}
+	}
+
+	switch {
+	case assessment.Score >= riskConf.DenyScore:
+		assessment.Action = enum.RiskActionDeny
+	case assessment.Score >= riskConf.StepUpScore && !attempt.StepUpPassed:
+		assessment.Action = enum.RiskActionStepUp
+	}
+	log.Info(rs.ctx, "login risk assessment", "userId", attempt.UserId, "ip", attempt.Ip, "assessment", assessment)
+	return assessment
+}
+
+// RecordLoginResult 把登录结果记录到登录历史和审计日志中
+func (rs *RiskDomainSvc) RecordLoginResult(attempt *do.LoginAttempt, assessment *do.RiskAssessment, result int8) {
+	history := &model.UserLoginHistory{
+		UserId:      attempt.UserId,
+		Platform:    attempt.Platform,
+		Ip:          attempt.Ip,
+		CountryCode: assessment.CountryCode,
+		City:        assessment.City,
+		Latitude:    assessment.Latitude,
+		Longitude:   assessment.Longitude,
+		DeviceId:    attempt.DeviceId,
+		UserAgent:   attempt.UserAgent,
+		Result:      result,
+		RiskScore:   assessment.Score,
+		RiskReasons: strings.Join(assessment.Reasons, ","),
+	}
+	if err := dao.NewLoginHistoryDao(rs.ctx).CreateLoginHistory(history); err != nil {
+		logger.New().Error(rs.ctx, "CreateLoginHistoryErr", "err", err, "history", history)
+	}
+
+	event := &do.AuditEvent{
+		UserId: attempt.UserId,
+		Ip:     attempt.Ip,
+		Detail: map[string]interface{}{
+			"platform":     attempt.Platform,
+			"device_id":    attempt.DeviceId,
+			"risk_score":   assessment.Score,
+			"risk_reasons": assessment.Reasons,
+		},
+	}
+	switch result {
+	case enum.LoginResultSuccess:
+		event.Event = enum.AuditEventLoginSuccess
+		if err := cache.ClearLoginFailure(rs.ctx, attempt.UserId); err != nil {
+			logger.New().Error(rs.ctx, "ClearLoginFailureErr", "err", err)
+		}
+	case enum.LoginResultStepUp:
+		event.Event = enum.AuditEventLoginStepUp
+	case enum.LoginResultDenied:
+		event.Event = enum.AuditEventLoginDenied
+	default:
+		event.Event = enum.AuditEventLoginFailed
+	}
+	NewAuditDomainSvc(rs.ctx).Record(event)
+}
+
+// RecordLoginFailure 记录一次登录失败(密码、验证码错误等), 窗口期内失败次数过多会提高后续登录的风险评分
+func (rs *RiskDomainSvc) RecordLoginFailure(attempt *do.LoginAttempt) {
+	_, err := cache.IncrLoginFailure(rs.ctx, attempt.UserId, config.Risk.FailureWindow)
+	if err != nil {
+		logger.New().Error(rs.ctx, "IncrLoginFailureErr", "err", err)
+	}
+	rs.RecordLoginResult(attempt, &do.RiskAssessment{}, enum.LoginResultFailed)
+}
+
+func (rs *RiskDomainSvc) isBadIp(ip string) bool {
+	for _, badIp := range config.Risk.BadIps {
+		if badIp == ip {
+			return true
+		}
+	}
+	isBad, err := cache.IsBadIp(rs.ctx, ip)
+	if err != nil {
+		logger.New().Error(rs.ctx, "IsBadIpErr", "err", err)
+		return false
+	}
+	return isBad
+}
+
+// fillIpLocation 查询IP的归属地, 内网IP不做查询
+// 查询结果在本地缓存, 归属地服务超时或出错时不缓存, 本次登录不计算地理位置相关的风险项
+func (rs *RiskDomainSvc) fillIpLocation(ip string, assessment *do.RiskAssessment) {
+	parsedIp := net.ParseIP(ip)
+	if parsedIp == nil || parsedIp.IsPrivate() || parsedIp.IsLoopback() {
+		return
+	}
+	locationCache := getIpLocationCache()
+	detail, ok := locationCache.Get(ip)
+	if !ok {
+		ctx, cancel := context.WithTimeout(rs.ctx, config.Risk.GeoLookupTimeout)
+		defer cancel()
+		var err error
+		if detail, err = library.NewWhoisLib(ctx).GetIpDetail(ip); err != nil {
+			return
+		}
+		locationCache.Set(ip, detail, config.Risk.GeoCacheTTL)
+	}
+	if !detail.Success {
+		return
+	}
+	assessment.CountryCode = detail.CountryCode
+	assessment.City = detail.City
+	assessment.Latitude = detail.Latitude
+	assessment.Longitude = detail.Longitude
+}
+
+// isImpossibleTravel 与上次成功登录的地点和时间相比, 需要的移动速度超过了配置的最大速度
+func (rs *RiskDomainSvc) isImpossibleTravel(lastLogin *model.UserLoginHistory, assessment *do.RiskAssessment) bool {
+	if assessment.CountryCode == "" || lastLogin.CountryCode == "" {
+		// 任意一次的地理位置未知
+		return false
+	}
+	distance := util.GeoDistance(lastLogin.Latitude, lastLogin.Longitude, assessment.Latitude, assessment.Longitude)
+	hours := time.Since(lastLogin.CreatedAt).Hours()
+	if hours < 1.0/60 {
+		// 间隔不到一分钟的按一分钟算, 避免除数过小
+		hours = 1.0 / 60
+	}
+	return distance/hours > config.Risk.MaxTravelSpeed
+}
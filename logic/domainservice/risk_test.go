@@ -0,0 +1,133 @@
+package domainservice
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/cache"
+	"github.com/ljinf/user_auth/dal/dao"
+	"github.com/ljinf/user_auth/dal/model"
+	"github.com/ljinf/user_auth/logic/do"
+)
+
+func TestEvaluateLogin(t *testing.T) {
+	const (
+		userId   = 1
+		deviceId = "device-known"
+	)
+	oldBadIps := config.Risk.BadIps
+	config.Risk.BadIps = []string{"10.0.0.66"}
+	t.Cleanup(func() { config.Risk.BadIps = oldBadIps })
+	weights := config.Risk.Weights
+
+	// 使用内网IP, 不会请求外部的IP归属地服务
+	tests := []struct {
+		name       string
+		history    bool  // 用户是否有成功登录的历史
+		failures   int64 // 窗口内登录失败次数
+		redisBadIp bool  // IP是否在Redis的黑名单中
+		attempt    do.LoginAttempt
+		wantScore  int
+		wantAction string
+		wantReason []string
+	}{
+		{
+			name:       "first login",
+			attempt:    do.LoginAttempt{Ip: "10.0.0.1", DeviceId: "device-new"},
+			wantAction: enum.RiskActionAllow,
+		},
+		{
+			name:       "known device",
+			history:    true,
+			attempt:    do.LoginAttempt{Ip: "10.0.0.1", DeviceId: deviceId},
+			wantAction: enum.RiskActionAllow,
+		},
+		{
+			name:       "new device",
+			history:    true,
+			attempt:    do.LoginAttempt{Ip: "10.0.0.1", DeviceId: "device-new"},
+			wantScore:  weights.NewDevice,
+			wantAction: enum.RiskActionAllow,
+			wantReason: []string{enum.RiskReasonNewDevice},
+		},
+		{
+			name:       "bad ip in config",
+			attempt:    do.LoginAttempt{Ip: "10.0.0.66"},
+			wantScore:  weights.BadIp,
+			wantAction: enum.RiskActionDeny,
+			wantReason: []string{enum.RiskReasonBadIp},
+		},
+		{
+			name:       "bad ip in redis",
+			redisBadIp: true,
+			attempt:    do.LoginAttempt{Ip: "10.0.0.1"},
+			wantScore:  weights.BadIp,
+			wantAction: enum.RiskActionDeny,
+			wantReason: []string{enum.RiskReasonBadIp},
+		},
+		{
+			name:       "failures below threshold",
+			failures:   config.Risk.FailureThreshold - 1,
+			attempt:    do.LoginAttempt{Ip: "10.0.0.1"},
+			wantAction: enum.RiskActionAllow,
+		},
+		{
+			name:       "too many failures",
+			failures:   config.Risk.FailureThreshold,
+			attempt:    do.LoginAttempt{Ip: "10.0.0.1"},
+			wantScore:  weights.TooManyFailures,
+			wantAction: enum.RiskActionStepUp,
+			wantReason: []string{enum.RiskReasonTooManyFailures},
+		},
+		{
+			name:       "too many failures after step up",
+			failures:   config.Risk.FailureThreshold,
+			attempt:    do.LoginAttempt{Ip: "10.0.0.1", StepUpPassed: true},
+			wantScore:  weights.TooManyFailures,
+			wantAction: enum.RiskActionAllow,
+			wantReason: []string{enum.RiskReasonTooManyFailures},
+		},
+		{
+			name:       "too many failures from new device",
+			history:    true,
+			failures:   config.Risk.FailureThreshold,
+			attempt:    do.LoginAttempt{Ip: "10.0.0.1", DeviceId: "device-new"},
+			wantScore:  weights.TooManyFailures + weights.NewDevice,
+			wantAction: enum.RiskActionStepUp,
+			wantReason: []string{enum.RiskReasonTooManyFailures, enum.RiskReasonNewDevice},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTestData(t)
+			ctx := context.Background()
+			if tt.history {
+				history := &model.UserLoginHistory{UserId: userId, Ip: "10.0.0.1", DeviceId: deviceId, Result: enum.LoginResultSuccess}
+				if err := dao.NewLoginHistoryDao(ctx).CreateLoginHistory(history); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i := int64(0); i < tt.failures; i++ {
+				if _, err := cache.IncrLoginFailure(ctx, userId, config.Risk.FailureWindow); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.redisBadIp {
+				testRedis.SAdd(enum.REDIS_KEY_RISK_BAD_IPS, tt.attempt.Ip)
+			}
+
+			attempt := tt.attempt
+			attempt.UserId = userId
+			assessment := NewRiskDomainSvc(ctx).EvaluateLogin(&attempt)
+			if assessment.Score != tt.wantScore || assessment.Action != tt.wantAction {
+				t.Errorf("EvaluateLogin() = %d %s, want %d %s", assessment.Score, assessment.Action, tt.wantScore, tt.wantAction)
+			}
+			if !reflect.DeepEqual(assessment.Reasons, tt.wantReason) {
+				t.Errorf("EvaluateLogin() reasons = %v, want %v", assessment.Reasons, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package domainservice
+
+import (
+	"context"
+	"crypto/subtle"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/logger"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/cache"
+	"github.com/ljinf/user_auth/logic/do"
+	"strings"
+	"time"
+)
+
+// TokenExchangeDomainSvc RFC 8693 Token交换
+// 后端服务用用户的Token换取一个只能访问指定目标服务、scope更小、有效期更短的Token
+type TokenExchangeDomainSvc struct {
+	ctx      context.Context
+	sessions cache.SessionStore
+}
+
+func NewTokenExchangeDomainSvc(ctx context.Context) *TokenExchangeDomainSvc {
+	return &TokenExchangeDomainSvc{ctx: ctx, sessions: cache.DefaultSessionStore()}
+}
+
+// AuthenticateClient 校验发起交换的后端服务的身份, 配置中只保存密钥的SHA-256
+func (ts *TokenExchangeDomainSvc) AuthenticateClient(clientId, secret string) (*config.ExchangeClient, error) {
+	client := findExchangeClient(clientId)
+	if client == nil || subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(util.Sha256Hex(secret))) != 1 {
+		return nil, errcode.ErrOAuthInvalidClient
+	}
+	return client, nil
+}
+
+// Exchange 用subjectToken换取访问audience的Token
+// subjectToken 可以是用户登录的AccessToken, 也可以是其他服务交换给当前服务的Token,
+// 后者再次交换时scope不能超出原Token, 并在调用链条中追加当前服务. 受限会话的Token交换时scope同样不能超出会话
+func (ts *TokenExchangeDomainSvc) Exchange(client *config.ExchangeClient, subjectToken, audience, scope, ip string) (*do.ExchangedToken, error) {
+	if !containsString(client.Audiences, audience) {
+		return nil, errcode.ErrOAuthInvalidTarget
+	}
+	subject, remaining, err := ts.findSubject(subjectToken, client.ClientId)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := client.Scopes
+	if subject.Actor != nil || subject.Scope != "" {
+		// 再次交换的Token和设备授权等受限会话, 交换出的scope都不能超出原Token
+		allowed = intersectScopes(allowed, strings.Fields(subject.Scope))
+	}
+	grantedScope, ok := narrowScope(scope, allowed)
+	if !ok || grantedScope == "" {
+		return nil, errcode.ErrOAuthInvalidScope
+	}
+
+	ttl := config.TokenExchange.TTL
+	if remaining < ttl {
+		// 交换出的Token不能比原Token活得更久
+		ttl = remaining
+	}
+	token, err := util.GenDelegatedToken(subject.UserId)
+	if err != nil {
+		return nil, errcode.Wrap("GenDelegatedTokenErr", err)
+	}
+	session := &do.SessionInfo{
+		UserId:      subject.UserId,
+		Platform:    subject.Platform,
+		SessionId:   subject.SessionId,
+		AccessToken: token,
+		Audience:    audience,
+		Scope:       grantedScope,
+		Actor:       &do.Actor{Subject: client.ClientId, Actor: subject.Actor},
+	}
+	if err = cache.SetExchangedToken(ts.ctx, token, session, ttl); err != nil {
+		return nil, errcode.Wrap("SetExchangedTokenErr", err)
+	}
+
+	NewAuditDomainSvc(ts.ctx).Record(&do.AuditEvent{
+		UserId: subject.UserId,
+		Event:  enum.AuditEventTokenExchange,
+		Ip:     ip,
+		Detail: map[string]interface{}{
+			"sessionId": subject.SessionId,
+			"audience":  audience,
+			"scope":     grantedScope,
+			"act":       session.Actor,
+		},
+	})
+	return &do.ExchangedToken{
+		AccessToken: token,
+		ExpiresIn:   int64(ttl / time.Second),
+		Scope:       grantedScope,
+		Audience:    audience,
+	}, nil
+}
+
+// findSubject 查找被交换的Token对应的会话, 返回会话和Token剩余的有效期
+func (ts *TokenExchangeDomainSvc) findSubject(subjectToken, clientId string) (*do.SessionInfo, time.Duration, error) {
+	session, err := ts.sessions.GetAccessToken(ts.ctx, subjectToken)
+	if err != nil {
+		return nil, 0, errcode.Wrap("GetAccessTokenErr", err)
+	}
+	if session.UserId != 0 {
+		remaining, err := ts.sessions.GetAccessTokenTTL(ts.ctx, subjectToken)
+		if err != nil {
+			return nil, 0, errcode.Wrap("GetAccessTokenTTLErr", err)
+		}
+		if remaining <= 0 {
+			// 查询到会话后Token刚好过期
+			return nil, 0, errcode.ErrOAuthInvalidGrant
+		}
+		return session, remaining, nil
+	}
+	exchanged, remaining, err := cache.GetExchangedToken(ts.ctx, subjectToken)
+	if err != nil {
+		return nil, 0, errcode.Wrap("GetExchangedTokenErr", err)
+	}
+	// 交换得到的Token只能由它的目标服务继续交换
+	if exchanged == nil || exchanged.Audience != clientId || remaining <= 0 {
+		logger.New().Warn(ts.ctx, "token exchange subject token invalid", "clientId", clientId)
+		return nil, 0, errcode.ErrOAuthInvalidGrant
+	}
+	return exchanged, remaining, nil
+}
+
+func findExchangeClient(clientId string) *config.ExchangeClient {
+	for i := range config.TokenExchange.Clients {
+		if config.TokenExchange.Clients[i].ClientId == clientId {
+			return &config.TokenExchange.Clients[i]
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func intersectScopes(a, b []string) []string {
+	result := make([]string, 0, len(a))
+	for _, s := range a {
+		if containsString(b, s) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -2,34 +2,175 @@ package domainservice
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/ljinf/user_auth/common/enum"
 	"github.com/ljinf/user_auth/common/errcode"
 	"github.com/ljinf/user_auth/common/logger"
 	"github.com/ljinf/user_auth/common/util"
 	"github.com/ljinf/user_auth/dal/cache"
+	"github.com/ljinf/user_auth/dal/cache/lock"
+	"github.com/ljinf/user_auth/dal/dao"
+	"github.com/ljinf/user_auth/dal/model"
 	"github.com/ljinf/user_auth/logic/do"
 	"time"
 )
 
 type UserDomainSvc struct {
-	ctx context.Context
+	ctx      context.Context
+	sessions cache.SessionStore
 }
 
 func NewUserDomainSvc(ctx context.Context) *UserDomainSvc {
-	return &UserDomainSvc{}
+	return NewUserDomainSvcWithStore(ctx, cache.DefaultSessionStore())
 }
 
-func (us *UserDomainSvc) GetUserBaseInfo(userId int64) *do.UserBaseInfo {
-	return &do.UserBaseInfo{
-		ID: 12348789,
+// NewUserDomainSvcWithStore 使用指定的SessionStore存储会话, 单元测试中可以传入 cache.NewMemorySessionStore()
+func NewUserDomainSvcWithStore(ctx context.Context, sessions cache.SessionStore) *UserDomainSvc {
+	return &UserDomainSvc{ctx: ctx, sessions: sessions}
+}
+
+// GetUserBaseInfo 获取用户的基本信息, 用户不存在时返回的UserBaseInfo.ID为0
+func (us *UserDomainSvc) GetUserBaseInfo(userId int64) (*do.UserBaseInfo, error) {
+	user, err := dao.NewUserDao(us.ctx).FindUserById(userId)
+	if err != nil {
+		err = errcode.Wrap("查询用户信息时发生错误", err)
+		return nil, err
+	}
+	return toUserBaseInfo(user)
+}
+
+// GetUserBaseInfoByPhone 通过手机号获取用户的基本信息, 用户不存在时返回的UserBaseInfo.ID为0
+func (us *UserDomainSvc) GetUserBaseInfoByPhone(phone string) (*do.UserBaseInfo, error) {
+	user, err := dao.NewUserDao(us.ctx).FindUserByPhone(phone)
+	if err != nil {
+		err = errcode.Wrap("查询用户信息时发生错误", err)
+		return nil, err
+	}
+	return toUserBaseInfo(user)
+}
+
+// GetUserBaseInfoByEmail 通过邮箱获取用户的基本信息, 用户不存在时返回的UserBaseInfo.ID为0
+func (us *UserDomainSvc) GetUserBaseInfoByEmail(email string) (*do.UserBaseInfo, error) {
+	user, err := dao.NewUserDao(us.ctx).FindUserByEmail(email)
+	if err != nil {
+		err = errcode.Wrap("查询用户信息时发生错误", err)
+		return nil, err
 	}
+	return toUserBaseInfo(user)
+}
+
+// GetUserBaseInfos 批量获取用户的基本信息, 不存在的用户不会出现在结果中
+func (us *UserDomainSvc) GetUserBaseInfos(userIds []int64) ([]*do.UserBaseInfo, error) {
+	users, err := dao.NewUserDao(us.ctx).FindUsersByIds(userIds)
+	if err != nil {
+		err = errcode.Wrap("查询用户信息时发生错误", err)
+		return nil, err
+	}
+	userInfos := make([]*do.UserBaseInfo, 0, len(users))
+	for _, user := range users {
+		userInfo, err := toUserBaseInfo(user)
+		if err != nil {
+			return nil, err
+		}
+		userInfos = append(userInfos, userInfo)
+	}
+	return userInfos, nil
+}
+
+// MarkUserVerified 用户完成邮箱验证后把用户标记为已认证
+func (us *UserDomainSvc) MarkUserVerified(userId int64) error {
+	err := dao.NewUserDao(us.ctx).UpdateUserVerified(userId, enum.UserVerifiedYes)
+	if err != nil {
+		err = errcode.Wrap("更新用户认证状态时发生错误", err)
+		return err
+	}
+	return nil
+}
+
+// RevokeAllSessions 删除用户在所有平台的Session和Token, 用户需要重新登录
+func (us *UserDomainSvc) RevokeAllSessions(userId int64) error {
+	err := us.sessions.DelUserAllSessions(us.ctx, userId)
+	if err != nil {
+		err = errcode.Wrap("删除用户Session时发生错误", err)
+		return err
+	}
+	us.publishSessionRevoked(userId, "")
+	return nil
+}
+
+// RevokeOtherSessions 删除用户除keepSessionId以外的其他Session和Token
+func (us *UserDomainSvc) RevokeOtherSessions(userId int64, keepSessionId string) error {
+	sessions, err := us.sessions.GetUserAllSessions(us.ctx, userId)
+	if err != nil {
+		err = errcode.Wrap("获取用户Session时发生错误", err)
+		return err
+	}
+	for _, session := range sessions {
+		if session.SessionId == keepSessionId {
+			continue
+		}
+		if err = us.sessions.DelUserPlatformSession(us.ctx, userId, session.Platform); err != nil {
+			err = errcode.Wrap("删除用户Session时发生错误", err)
+			return err
+		}
+		us.publishSessionRevoked(userId, session.SessionId)
+	}
+	return nil
+}
+
+// RevokeSession 吊销用户的某个Session, Session不存在时返回ErrNotFound
+func (us *UserDomainSvc) RevokeSession(userId int64, sessionId string) error {
+	sessions, err := us.sessions.GetUserAllSessions(us.ctx, userId)
+	if err != nil {
+		err = errcode.Wrap("获取用户Session时发生错误", err)
+		return err
+	}
+	for _, session := range sessions {
+		if session.SessionId != sessionId {
+			continue
+		}
+		if err = us.sessions.DelUserPlatformSession(us.ctx, userId, session.Platform); err != nil {
+			err = errcode.Wrap("删除用户Session时发生错误", err)
+			return err
+		}
+		us.publishSessionRevoked(userId, sessionId)
+		return nil
+	}
+	return errcode.ErrNotFound
+}
+
+// publishSessionRevoked 通知下游服务会话已被吊销, 通知失败时下游服务的缓存会在过期后自然失效, 只记录错误日志
+func (us *UserDomainSvc) publishSessionRevoked(userId int64, sessionId string) {
+	event := &do.SessionRevokedEvent{UserId: userId, SessionId: sessionId}
+	if err := cache.PublishSessionRevoked(us.ctx, event); err != nil {
+		logger.New().Error(us.ctx, "PublishSessionRevokedErr", "err", err, "event", event)
+	}
+}
+
+func toUserBaseInfo(user *model.User) (*do.UserBaseInfo, error) {
+	userInfo := new(do.UserBaseInfo)
+	if user == nil {
+		return userInfo, nil
+	}
+	err := util.CopyProperties(userInfo, user)
+	if err != nil {
+		err = errcode.Wrap("复制用户信息时发生错误", err)
+		return nil, err
+	}
+	userInfo.ID = user.Id
+	return userInfo, nil
 }
 
 // GenAuthToken 生成AccessToken和RefreshToken
 // 在缓存中会存储最新的Token 以及与Platform对应的 UserSession 同时会删除缓存中旧的Token-其中RefreshToken采用的是延迟删除
-// **UserSession 在设置时会覆盖掉旧的Session信息
-func (us *UserDomainSvc) GenAuthToken(userId int64, platform string, sessionId string) (*do.TokenInfo, error) {
-	user := us.GetUserBaseInfo(userId)
+// **UserSession 在设置时会覆盖掉旧的Session信息, 以上操作原子地完成, 不会出现Session指向不存在的Token的情况
+// scope 不为空时生成的是受限会话, 只能访问要求了这些scope的接口
+func (us *UserDomainSvc) GenAuthToken(userId int64, platform, sessionId, scope string) (*do.TokenInfo, error) {
+	user, err := us.GetUserBaseInfo(userId)
+	if err != nil {
+		return nil, err
+	}
 	// 处理参数异常情况, 用户不存在、被删除、被禁用
 	if user.ID == 0 || user.IsBlocked == enum.UserBlockStateBlocked {
 		err := errcode.ErrUserInvalid
@@ -43,29 +184,23 @@ func (us *UserDomainSvc) GenAuthToken(userId int64, platform string, sessionId s
 		sessionId = util.GenSessionId(userId)
 	}
 	userSession.SessionId = sessionId
+	userSession.Scope = scope
 	accessToken, refreshToken, err := util.GenUserAuthToken(userId)
-	// 设置 userSession 缓存
-	userSession.AccessToken = accessToken
-	userSession.RefreshToken = refreshToken
 	if err != nil {
 		err = errcode.Wrap("Token生成失败", err)
 		return nil, err
 	}
-	// 向缓存中设置AccessToken和RefreshToken的缓存
-	err = cache.SetUserToken(us.ctx, userSession)
-	if err != nil {
-		errcode.Wrap("设置Token缓存时发生错误", err)
-		return nil, err
-	}
-	err = cache.DelOldSessionTokens(us.ctx, userSession)
+	userSession.AccessToken = accessToken
+	userSession.RefreshToken = refreshToken
+	// 设置新Token、使旧Token失效、覆盖旧Session在一个Redis脚本中原子地完成
+	oldSession, err := us.sessions.IssueSessionTokens(us.ctx, userSession)
 	if err != nil {
-		errcode.Wrap("删除旧Token时发生错误", err)
+		err = errcode.Wrap("设置Token缓存时发生错误", err)
 		return nil, err
 	}
-	err = cache.SetUserSession(us.ctx, userSession)
-	if err != nil {
-		errcode.Wrap("设置Session缓存时发生错误", err)
-		return nil, err
+	if oldSession != nil && oldSession.SessionId != sessionId {
+		// 同平台重新登录挤掉了旧的Session, 通知下游服务
+		us.publishSessionRevoked(userId, oldSession.SessionId)
 	}
 
 	srvCreateTime := time.Now()
@@ -79,19 +214,55 @@ func (us *UserDomainSvc) GenAuthToken(userId int64, platform string, sessionId s
 	return tokenInfo, nil
 }
 
+// Login 用户通过身份校验后调用, 在生成Token前先由风控引擎评估本次登录尝试
+// 高风险的登录需要二次验证或者直接被拒绝, 评估结果会记录到登录历史和审计日志中
+func (us *UserDomainSvc) Login(attempt *do.LoginAttempt) (*do.TokenInfo, error) {
+	riskSvc := NewRiskDomainSvc(us.ctx)
+	assessment := riskSvc.EvaluateLogin(attempt)
+	switch assessment.Action {
+	case enum.RiskActionDeny:
+		riskSvc.RecordLoginResult(attempt, assessment, enum.LoginResultDenied)
+		return nil, errcode.ErrLoginDenied
+	case enum.RiskActionStepUp:
+		riskSvc.RecordLoginResult(attempt, assessment, enum.LoginResultStepUp)
+		return nil, errcode.ErrLoginNeedStepUp
+	}
+	// 登录行为sessionId传空, 生成新的Session
+	tokenInfo, err := us.GenAuthToken(attempt.UserId, attempt.Platform, "", attempt.Scope)
+	if err != nil {
+		return nil, err
+	}
+	riskSvc.RecordLoginResult(attempt, assessment, enum.LoginResultSuccess)
+	return tokenInfo, nil
+}
+
+// RefreshToken 用RefreshToken换取新的Token
+// 同一个RefreshToken并发刷新时, 后到的请求等待先到的请求刷新完成, 在 RefreshTokenGraceDuration 内重复刷新都返回同一组新Token
 func (us *UserDomainSvc) RefreshToken(refreshToken string) (*do.TokenInfo, error) {
 	log := logger.New()
-	ok, err := cache.LockTokenRefresh(us.ctx, refreshToken)
-	defer cache.UnlockTokenRefresh(us.ctx, refreshToken)
+	if tokenInfo := us.getRefreshResult(refreshToken); tokenInfo != nil {
+		return tokenInfo, nil
+	}
+	lockKey := fmt.Sprintf(enum.REDISKEY_TOKEN_REFRESH_LOCK, refreshToken)
+	waitCtx, cancel := context.WithTimeout(us.ctx, enum.RefreshTokenLockWait)
+	defer cancel()
+	refreshLock, err := us.sessions.Locker().Obtain(waitCtx, lockKey, 10*time.Second, lock.WithWait(50*time.Millisecond))
+	if errors.Is(err, lock.ErrNotObtained) {
+		// 同一个RefreshToken正在被其他请求刷新, 等待超时
+		err = errcode.ErrTooManyRequests
+		return nil, err
+	}
 	if err != nil {
 		err = errcode.Wrap("刷新Token时设置Redis锁发生错误", err)
 		return nil, err
 	}
-	if !ok {
-		err = errcode.ErrTooManyRequests
-		return nil, err
+	// 只有拿到锁才释放, 释放时校验owner token, 不会删掉其他请求的锁
+	defer refreshLock.Release(us.ctx)
+	// 等待锁期间先到的请求可能已经刷新完成
+	if tokenInfo := us.getRefreshResult(refreshToken); tokenInfo != nil {
+		return tokenInfo, nil
 	}
-	tokenSession, err := cache.GetRefreshToken(us.ctx, refreshToken)
+	tokenSession, err := us.sessions.GetRefreshToken(us.ctx, refreshToken)
 	if err != nil {
 		log.Error(us.ctx, "GetRefreshTokenCacheErr", "err", err)
 		// 服务端发生错误一律提示客户端Token有问题
@@ -104,14 +275,19 @@ func (us *UserDomainSvc) RefreshToken(refreshToken string) (*do.TokenInfo, error
 		err = errcode.ErrToken
 		return nil, err
 	}
-	userSession, err := cache.GetUserPlatformSession(us.ctx, tokenSession.UserId, tokenSession.Platform)
+	userSession, err := us.sessions.GetUserPlatformSession(us.ctx, tokenSession.UserId, tokenSession.Platform)
 	if err != nil {
 		log.Error(us.ctx, "GetUserPlatformSessionErr", "err", err)
 		err = errcode.ErrToken
 		return nil, err
 	}
+	// Session已经被删除, 用户退出了登录或者会话被吊销
+	if userSession == nil {
+		err = errcode.ErrToken
+		return nil, err
+	}
 	// 请求刷新的RefreshToken与UserSession中的不一致, 证明这个RefreshToken已经过时
-	// RefreshToken被窃取或者前端页面刷Token不是串行的互斥操作都有可能造成这种情况
+	// 超出并发刷新的时间窗口后还在使用, RefreshToken有可能被窃取
 	if userSession.RefreshToken != refreshToken {
 		// 记一条警告日志
 		log.Warn(us.ctx, "ExpiredRefreshToken", "requestToken", refreshToken, "newToken", userSession.RefreshToken, "userId", userSession.UserId)
@@ -120,17 +296,43 @@ func (us *UserDomainSvc) RefreshToken(refreshToken string) (*do.TokenInfo, error
 		return nil, err
 	}
 
-	// 重新生成Token  因为不是用户主动登录所以sessionID与之前的保持一致
-	tokenInfo, err := us.GenAuthToken(tokenSession.UserId, tokenSession.Platform, tokenSession.SessionId)
+	// 重新生成Token  因为不是用户主动登录所以sessionID与之前的保持一致, 受限会话刷新后scope不变
+	tokenInfo, err := us.GenAuthToken(tokenSession.UserId, tokenSession.Platform, tokenSession.SessionId, tokenSession.Scope)
 	if err != nil {
 		err = errcode.Wrap("GenAuthTokenErr", err)
 		return nil, err
 	}
+	if err = us.sessions.SetRefreshResult(us.ctx, refreshToken, tokenInfo, enum.RefreshTokenGraceDuration); err != nil {
+		// 只影响并发刷新的请求, 新Token已经生效, 不影响本次刷新
+		log.Error(us.ctx, "SetRefreshResultErr", "err", err)
+	}
 	return tokenInfo, nil
 }
 
+// getRefreshResult 查询RefreshToken在时间窗口内刷新得到的新Token
+// 新Token已经失效(用户退出登录、会话被吊销或者新Token又被刷新)时返回nil, 查询出错时也返回nil, 按正常流程刷新
+func (us *UserDomainSvc) getRefreshResult(refreshToken string) *do.TokenInfo {
+	tokenInfo, err := us.sessions.GetRefreshResult(us.ctx, refreshToken)
+	if err != nil {
+		logger.New().Error(us.ctx, "GetRefreshResultErr", "err", err)
+		return nil
+	}
+	if tokenInfo == nil {
+		return nil
+	}
+	session, err := us.sessions.GetAccessToken(us.ctx, tokenInfo.AccessToken)
+	if err != nil {
+		logger.New().Error(us.ctx, "GetAccessTokenErr", "err", err)
+		return nil
+	}
+	if session.UserId == 0 {
+		return nil
+	}
+	return tokenInfo
+}
+
 func (us *UserDomainSvc) VerifyAccessToken(accessToken string) (*do.TokenVerify, error) {
-	tokenInfo, err := cache.GetAccessToken(us.ctx, accessToken)
+	tokenInfo, err := us.sessions.GetAccessToken(us.ctx, accessToken)
 	if err != nil {
 		logger.New().Error(us.ctx, "GetAccessTokenErr", "err", err)
 		return nil, err
@@ -139,6 +341,8 @@ func (us *UserDomainSvc) VerifyAccessToken(accessToken string) (*do.TokenVerify,
 	if tokenInfo != nil && tokenInfo.UserId != 0 {
 		tokenVerify.UserId = tokenInfo.UserId
 		tokenVerify.SessionId = tokenInfo.SessionId
+		tokenVerify.Platform = tokenInfo.Platform
+		tokenVerify.Scope = tokenInfo.Scope
 		tokenVerify.Approved = true
 	} else {
 		tokenVerify.Approved = false
@@ -0,0 +1,135 @@
+package domainservice
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/dal/cache"
+	"github.com/ljinf/user_auth/dal/model"
+	"github.com/ljinf/user_auth/logic/do"
+)
+
+func errCode(err error) int {
+	var appErr *errcode.AppError
+	if errors.As(err, &appErr) {
+		return appErr.Code()
+	}
+	return 0
+}
+
+func TestRefreshToken(t *testing.T) {
+	tests := []struct {
+		name string
+		// prepare 登录后返回用来刷新的RefreshToken
+		prepare  func(t *testing.T, svc *UserDomainSvc, userId int64, login *do.TokenInfo) string
+		wantCode int
+	}{
+		{
+			name: "valid",
+			prepare: func(t *testing.T, svc *UserDomainSvc, userId int64, login *do.TokenInfo) string {
+				return login.RefreshToken
+			},
+		},
+		{
+			name: "unknown token",
+			prepare: func(t *testing.T, svc *UserDomainSvc, userId int64, login *do.TokenInfo) string {
+				return "unknown"
+			},
+			wantCode: errcode.ErrToken.Code(),
+		},
+		{
+			name: "revoked session",
+			prepare: func(t *testing.T, svc *UserDomainSvc, userId int64, login *do.TokenInfo) string {
+				if err := svc.sessions.DelUserAllSessions(context.Background(), userId); err != nil {
+					t.Fatal(err)
+				}
+				return login.RefreshToken
+			},
+			wantCode: errcode.ErrToken.Code(),
+		},
+		{
+			name: "stale token after re-login",
+			prepare: func(t *testing.T, svc *UserDomainSvc, userId int64, login *do.TokenInfo) string {
+				// 同平台重新登录, 旧的RefreshToken延迟删除期间还存在, 但已经不是Session中的RefreshToken
+				if _, err := svc.GenAuthToken(userId, enum.PlatformApp, "", ""); err != nil {
+					t.Fatal(err)
+				}
+				return login.RefreshToken
+			},
+			wantCode: errcode.ErrToken.Code(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTestData(t)
+			user := createTestUser(t, &model.User{Nickname: "test"})
+			svc := NewUserDomainSvcWithStore(context.Background(), cache.NewMemorySessionStore())
+			login, err := svc.GenAuthToken(user.Id, enum.PlatformApp, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			refreshToken := tt.prepare(t, svc, user.Id, login)
+
+			tokenInfo, err := svc.RefreshToken(refreshToken)
+			if code := errCode(err); code != tt.wantCode {
+				t.Fatalf("RefreshToken() err = %v, want code %d", err, tt.wantCode)
+			}
+			if tt.wantCode != 0 {
+				return
+			}
+			if tokenInfo.RefreshToken == login.RefreshToken || tokenInfo.AccessToken == login.AccessToken {
+				t.Error("RefreshToken() did not issue new tokens")
+			}
+			session, err := svc.sessions.GetAccessToken(context.Background(), tokenInfo.AccessToken)
+			if err != nil || session == nil {
+				t.Fatalf("new access token not stored: %v", err)
+			}
+			if session.SessionId == "" {
+				t.Error("refreshed session has no session id")
+			}
+		})
+	}
+}
+
+func TestRefreshTokenConcurrent(t *testing.T) {
+	resetTestData(t)
+	user := createTestUser(t, &model.User{Nickname: "test"})
+	svc := NewUserDomainSvcWithStore(context.Background(), cache.NewMemorySessionStore())
+	login, err := svc.GenAuthToken(user.Id, enum.PlatformApp, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 客户端并发刷新同一个RefreshToken, 所有请求拿到同一组新Token
+	const concurrency = 10
+	var wg sync.WaitGroup
+	results := make([]*do.TokenInfo, concurrency)
+	errs := make([]error, concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = svc.RefreshToken(login.RefreshToken)
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < concurrency; i++ {
+		if errs[i] != nil {
+			t.Fatalf("RefreshToken() #%d err = %v", i, errs[i])
+		}
+		if results[i].AccessToken != results[0].AccessToken || results[i].RefreshToken != results[0].RefreshToken {
+			t.Fatalf("RefreshToken() #%d returned different tokens", i)
+		}
+	}
+	sessions, err := svc.sessions.GetUserAllSessions(context.Background(), user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].RefreshToken != results[0].RefreshToken {
+		t.Errorf("user sessions = %+v, want the single refreshed session", sessions)
+	}
+}
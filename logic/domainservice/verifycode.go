@@ -0,0 +1,93 @@
+package domainservice
+
+import (
+	"context"
+	"fmt"
+	"github.com/ljinf/user_auth/common/errcode"
+	"github.com/ljinf/user_auth/common/util"
+	"github.com/ljinf/user_auth/config"
+	"github.com/ljinf/user_auth/dal/cache"
+	"github.com/ljinf/user_auth/library"
+)
+
+// VerifyCodeDomainSvc 短信、邮件一次性验证码的发送和校验
+type VerifyCodeDomainSvc struct {
+	ctx context.Context
+}
+
+func NewVerifyCodeDomainSvc(ctx context.Context) *VerifyCodeDomainSvc {
+	return &VerifyCodeDomainSvc{ctx: ctx}
+}
+
+// SendSmsCode 向手机号发送指定场景的短信验证码
+func (vs *VerifyCodeDomainSvc) SendSmsCode(scene, phone string) error {
+	code, err := vs.genCode(scene, phone)
+	if err != nil {
+		return err
+	}
+	content := fmt.Sprintf("【%s】您的验证码为%s, %d分钟内有效, 请勿泄露给他人。",
+		config.Sms.SignName, code, int(config.VerifyCode.TTL.Minutes()))
+	smsSender, err := library.NewSmsSender()
+	if err == nil {
+		err = smsSender.Send(vs.ctx, phone, content)
+	}
+	if err != nil {
+		err = errcode.Wrap("发送短信验证码时发生错误", err)
+		return err
+	}
+	return nil
+}
+
+// SendEmailCode 向邮箱发送指定场景的邮件验证码, locale 为邮件模板使用的语言
+func (vs *VerifyCodeDomainSvc) SendEmailCode(scene, email, locale string) error {
+	code, err := vs.genCode(scene, email)
+	if err != nil {
+		return err
+	}
+	return NewNotificationDomainSvc(vs.ctx).SendMail(email, scene+"_code", locale, map[string]interface{}{
+		"Code":    code,
+		"Minutes": int(config.VerifyCode.TTL.Minutes()),
+	})
+}
+
+// CheckCode 校验验证码, 校验通过后验证码立即失效
+// 错误次数超过配置的上限后验证码同样失效, 需要重新获取
+// 校验次数的累加、比较和删除在一个Lua脚本中完成, 并发请求不能重复使用同一个验证码, 也不能绕过次数限制
+func (vs *VerifyCodeDomainSvc) CheckCode(scene, target, code string) error {
+	result, err := cache.CheckAndDelVerifyCode(vs.ctx, scene, target, code, config.VerifyCode.MaxAttempts)
+	if err != nil {
+		err = errcode.Wrap("校验验证码时发生错误", err)
+		return err
+	}
+	switch result {
+	case cache.VerifyCodeMatched:
+		return nil
+	case cache.VerifyCodeAttemptsExceeded:
+		return errcode.ErrVerifyCodeAttemptsExceeded
+	default:
+		return errcode.ErrVerifyCodeInvalid
+	}
+}
+
+// genCode 生成验证码并写入缓存, 冷却期内重复请求会返回 ErrVerifyCodeSendTooFrequent
+func (vs *VerifyCodeDomainSvc) genCode(scene, target string) (string, error) {
+	ok, err := cache.LockVerifyCodeResend(vs.ctx, scene, target, config.VerifyCode.ResendCooldown)
+	if err != nil {
+		err = errcode.Wrap("设置验证码发送冷却期时发生错误", err)
+		return "", err
+	}
+	if !ok {
+		return "", errcode.ErrVerifyCodeSendTooFrequent
+	}
+	code, err := util.SecureRandNumStr(config.VerifyCode.Length)
+	if err != nil {
+		err = errcode.Wrap("生成验证码时发生错误", err)
+		return "", err
+	}
+	err = cache.SetVerifyCode(vs.ctx, scene, target, code, config.VerifyCode.TTL)
+	if err != nil {
+		err = errcode.Wrap("设置验证码缓存时发生错误", err)
+		return "", err
+	}
+	return code, nil
+}
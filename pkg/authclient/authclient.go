@@ -0,0 +1,135 @@
+// Package authclient 提供给下游服务使用的认证SDK
+// 通过HTTP(网关转发认证接口 /auth/verify)或者gRPC(AuthService)验证用户的Token,
+// 在本地缓存验证结果, 并通过订阅会话吊销事件及时清理缓存
+package authclient
+
+import (
+	"context"
+	"errors"
+	"github.com/ljinf/user_auth/common/util"
+	"time"
+)
+
+// ErrInvalidToken Token无效或者已过期
+var ErrInvalidToken = errors.New("authclient: invalid token")
+
+// Identity Token验证通过后得到的用户身份
+type Identity struct {
+	PrincipalType string // user 或 api_key, 通过gRPC验证时为空
+	UserId        int64
+	SessionId     string // 用户登录的会话才有
+	Platform      string // 用户登录的会话才有
+	Scope         string // API Key和受限会话允许的scope, 空格分隔, 登录会话为空表示完整的登录会话
+}
+
+// Verifier 到认证服务验证Token, Token无效时返回 ErrInvalidToken
+type Verifier interface {
+	Verify(ctx context.Context, token string) (*Identity, error)
+}
+
+// Client 带本地缓存的Token验证客户端
+// 验证通过的结果缓存 positiveTTL, 验证不通过的结果缓存 negativeTTL, 与认证服务通信出错时不缓存
+// 没有订阅吊销事件时, 被吊销的Token最多在 positiveTTL 后失效
+type Client struct {
+	verifier    Verifier
+	cache       *tokenCache
+	positiveTTL time.Duration
+	negativeTTL time.Duration
+}
+
+func New(verifier Verifier, options ...Option) *Client {
+	opts := defaultClientOptions()
+	for _, opt := range options {
+		opt.apply(opts)
+	}
+	return &Client{
+		verifier:    verifier,
+		cache:       newTokenCache(opts.maxEntries),
+		positiveTTL: opts.positiveTTL,
+		negativeTTL: opts.negativeTTL,
+	}
+}
+
+// Verify 验证Token, 优先使用本地缓存的结果
+func (c *Client) Verify(ctx context.Context, token string) (*Identity, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+	cacheKey := util.Sha256Hex(token)
+	if identity, found := c.cache.get(cacheKey); found {
+		if identity == nil {
+			return nil, ErrInvalidToken
+		}
+		return identity, nil
+	}
+	identity, err := c.verifier.Verify(ctx, token)
+	if errors.Is(err, ErrInvalidToken) {
+		if c.negativeTTL > 0 {
+			c.cache.set(cacheKey, nil, c.negativeTTL)
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.positiveTTL > 0 {
+		c.cache.set(cacheKey, identity, c.positiveTTL)
+	}
+	return identity, nil
+}
+
+// Revoke 清理被吊销的会话的缓存, sessionId 为空时清理用户全部会话的缓存
+// 订阅了吊销事件时会自动调用, 使用其他方式接收吊销通知时可以直接调用
+func (c *Client) Revoke(userId int64, sessionId string) {
+	if sessionId == "" {
+		c.cache.evictUser(userId)
+		return
+	}
+	c.cache.evictSession(sessionId)
+}
+
+// 针对可选的客户端配置项, 与 httptool 一样使用Options模式
+type clientOption struct {
+	positiveTTL time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+}
+
+type Option interface {
+	apply(opts *clientOption)
+}
+
+type optionFunc func(opts *clientOption)
+
+func (f optionFunc) apply(opts *clientOption) {
+	f(opts)
+}
+
+func defaultClientOptions() *clientOption {
+	return &clientOption{
+		positiveTTL: 30 * time.Second,
+		negativeTTL: 5 * time.Second,
+		maxEntries:  10000,
+	}
+}
+
+// WithPositiveTTL 验证通过的结果的缓存时间, 为0时不缓存
+func WithPositiveTTL(ttl time.Duration) Option {
+	return optionFunc(func(opts *clientOption) {
+		opts.positiveTTL = ttl
+	})
+}
+
+// WithNegativeTTL 验证不通过的结果的缓存时间, 为0时不缓存
+func WithNegativeTTL(ttl time.Duration) Option {
+	return optionFunc(func(opts *clientOption) {
+		opts.negativeTTL = ttl
+	})
+}
+
+// WithMaxEntries 本地最多缓存的验证结果数量
+func WithMaxEntries(maxEntries int) Option {
+	return optionFunc(func(opts *clientOption) {
+		opts.maxEntries = maxEntries
+	})
+}
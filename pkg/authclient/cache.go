@@ -0,0 +1,122 @@
+package authclient
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenCache 缓存Token的验证结果, 按用户和会话建立索引, 收到吊销事件时可以找到需要清理的Token
+type tokenCache struct {
+	mu         sync.Mutex
+	entries    map[string]*cacheEntry
+	sessions   map[string]map[string]struct{}
+	users      map[int64]map[string]struct{}
+	maxEntries int
+}
+
+type cacheEntry struct {
+	identity *Identity // 为nil表示Token无效
+	expireAt time.Time
+}
+
+func newTokenCache(maxEntries int) *tokenCache {
+	return &tokenCache{
+		entries:    make(map[string]*cacheEntry),
+		sessions:   make(map[string]map[string]struct{}),
+		users:      make(map[int64]map[string]struct{}),
+		maxEntries: maxEntries,
+	}
+}
+
+// get 返回缓存的验证结果, found 为false表示没有缓存
+func (tc *tokenCache) get(key string) (identity *Identity, found bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	entry, ok := tc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expireAt) {
+		tc.remove(key)
+		return nil, false
+	}
+	return entry.identity, true
+}
+
+func (tc *tokenCache) set(key string, identity *Identity, ttl time.Duration) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.remove(key)
+	if tc.maxEntries > 0 && len(tc.entries) >= tc.maxEntries {
+		tc.evict()
+	}
+	tc.entries[key] = &cacheEntry{identity: identity, expireAt: time.Now().Add(ttl)}
+	if identity == nil {
+		return
+	}
+	if tc.sessions[identity.SessionId] == nil {
+		tc.sessions[identity.SessionId] = make(map[string]struct{})
+	}
+	tc.sessions[identity.SessionId][key] = struct{}{}
+	if tc.users[identity.UserId] == nil {
+		tc.users[identity.UserId] = make(map[string]struct{})
+	}
+	tc.users[identity.UserId][key] = struct{}{}
+}
+
+func (tc *tokenCache) evictSession(sessionId string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	for key := range tc.sessions[sessionId] {
+		tc.remove(key)
+	}
+}
+
+func (tc *tokenCache) evictUser(userId int64) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	for key := range tc.users[userId] {
+		tc.remove(key)
+	}
+}
+
+// remove 删除缓存并维护索引, 调用方需要持有锁
+func (tc *tokenCache) remove(key string) {
+	entry, ok := tc.entries[key]
+	if !ok {
+		return
+	}
+	delete(tc.entries, key)
+	if entry.identity == nil {
+		return
+	}
+	if keys := tc.sessions[entry.identity.SessionId]; keys != nil {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(tc.sessions, entry.identity.SessionId)
+		}
+	}
+	if keys := tc.users[entry.identity.UserId]; keys != nil {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(tc.users, entry.identity.UserId)
+		}
+	}
+}
+
+// evict 缓存满时先清理过期的条目, 仍然满时随机淘汰一个条目, 调用方需要持有锁
+func (tc *tokenCache) evict() {
+	now := time.Now()
+	for key, entry := range tc.entries {
+		if now.After(entry.expireAt) {
+			tc.remove(key)
+		}
+	}
+	if len(tc.entries) < tc.maxEntries {
+		return
+	}
+	for key := range tc.entries {
+		tc.remove(key)
+		return
+	}
+}
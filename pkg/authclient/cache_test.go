@@ -0,0 +1,103 @@
+package authclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenCache(t *testing.T) {
+	alice := &Identity{UserId: 1, SessionId: "s1"}
+	aliceOther := &Identity{UserId: 1, SessionId: "s2"}
+	bob := &Identity{UserId: 2, SessionId: "s3"}
+
+	tests := []struct {
+		name    string
+		run     func(tc *tokenCache)
+		present []string
+		absent  []string
+	}{
+		{
+			name:    "hit",
+			run:     func(tc *tokenCache) { tc.set("a", alice, time.Minute) },
+			present: []string{"a"},
+		},
+		{
+			name:   "expired",
+			run:    func(tc *tokenCache) { tc.set("a", alice, -time.Second) },
+			absent: []string{"a"},
+		},
+		{
+			name: "evict session",
+			run: func(tc *tokenCache) {
+				tc.set("a", alice, time.Minute)
+				tc.set("b", aliceOther, time.Minute)
+				tc.evictSession("s1")
+			},
+			present: []string{"b"},
+			absent:  []string{"a"},
+		},
+		{
+			name: "evict user",
+			run: func(tc *tokenCache) {
+				tc.set("a", alice, time.Minute)
+				tc.set("b", aliceOther, time.Minute)
+				tc.set("c", bob, time.Minute)
+				tc.evictUser(1)
+			},
+			present: []string{"c"},
+			absent:  []string{"a", "b"},
+		},
+		{
+			name: "overwrite moves index",
+			run: func(tc *tokenCache) {
+				tc.set("a", alice, time.Minute)
+				tc.set("a", bob, time.Minute)
+				tc.evictUser(1)
+			},
+			present: []string{"a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := newTokenCache(0)
+			tt.run(tc)
+			for _, key := range tt.present {
+				if _, found := tc.get(key); !found {
+					t.Errorf("get(%q) not found", key)
+				}
+			}
+			for _, key := range tt.absent {
+				if _, found := tc.get(key); found {
+					t.Errorf("get(%q) found", key)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenCacheInvalidToken(t *testing.T) {
+	tc := newTokenCache(0)
+	tc.set("bad", nil, time.Minute)
+	identity, found := tc.get("bad")
+	if !found || identity != nil {
+		t.Fatalf("get() = %v, %v, want nil, true", identity, found)
+	}
+}
+
+func TestTokenCacheMaxEntries(t *testing.T) {
+	tc := newTokenCache(2)
+	tc.set("expired", &Identity{UserId: 1, SessionId: "s1"}, -time.Second)
+	tc.set("a", &Identity{UserId: 2, SessionId: "s2"}, time.Minute)
+	// 缓存满时先清理过期的条目
+	tc.set("b", &Identity{UserId: 3, SessionId: "s3"}, time.Minute)
+	if _, found := tc.get("a"); !found {
+		t.Error("get(a) not found, want expired entry evicted first")
+	}
+	tc.set("c", &Identity{UserId: 4, SessionId: "s4"}, time.Minute)
+	if len(tc.entries) != 2 {
+		t.Errorf("len(entries) = %d, want 2", len(tc.entries))
+	}
+	if len(tc.sessions) != 2 || len(tc.users) != 2 {
+		t.Errorf("index size = %d/%d, want 2/2", len(tc.sessions), len(tc.users))
+	}
+}
@@ -0,0 +1,78 @@
+package authclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/ljinf/user_auth/api/proto/authpb"
+	"github.com/ljinf/user_auth/common/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// grpcVerifier 通过认证服务的 gRPC AuthService 验证Token
+type grpcVerifier struct {
+	client authpb.AuthServiceClient
+}
+
+// NewGrpcVerifier conn 为连接认证服务gRPC端口的连接, 由调用方创建和关闭
+// 认证服务要求调用方使用客户端证书, 或者创建连接时通过 SignUnaryClientInterceptor 对请求签名
+func NewGrpcVerifier(conn grpc.ClientConnInterface) Verifier {
+	return &grpcVerifier{client: authpb.NewAuthServiceClient(conn)}
+}
+
+func (gv *grpcVerifier) Verify(ctx context.Context, token string) (*Identity, error) {
+	resp, err := gv.client.VerifyAccessToken(withTraceMetadata(ctx), &authpb.VerifyAccessTokenRequest{AccessToken: token})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.GetApproved() {
+		return nil, ErrInvalidToken
+	}
+	return &Identity{
+		UserId:    resp.GetUserId(),
+		SessionId: resp.GetSessionId(),
+		Platform:  resp.GetPlatform(),
+		Scope:     resp.GetScope(),
+	}, nil
+}
+
+// withTraceMetadata 在metadata中添加追踪信息, 与认证服务的 traceid、spanid 对应
+func withTraceMetadata(ctx context.Context) context.Context {
+	traceId, spanId, _ := util.GetTraceInfoFromCtx(ctx)
+	if traceId == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "traceid", traceId, "spanid", spanId)
+}
+
+// SignUnaryClientInterceptor 使用认证服务分配的密钥对gRPC请求签名, 没有客户端证书时创建连接使用:
+// grpc.Dial(addr, grpc.WithUnaryInterceptor(authclient.SignUnaryClientInterceptor(keyId, secret)))
+// 签名的请求方法固定为POST, 请求路径为RPC的完整方法名, 请求体为请求消息的确定性序列化结果
+func SignUnaryClientInterceptor(keyId, secret string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		message, ok := req.(proto.Message)
+		if !ok {
+			return fmt.Errorf("authclient: unsupported request message %T", req)
+		}
+		body, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+		if err != nil {
+			return err
+		}
+		nonce, err := util.SecureRandString(32, util.Alphanumeric)
+		if err != nil {
+			return err
+		}
+		timestamp := time.Now().Unix()
+		ctx = metadata.AppendToOutgoingContext(ctx,
+			util.SignHeaderKeyId, keyId,
+			util.SignHeaderTimestamp, strconv.FormatInt(timestamp, 10),
+			util.SignHeaderNonce, nonce,
+			util.SignHeaderSignature, util.SignRequest(secret, http.MethodPost, method, body, timestamp, nonce),
+		)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
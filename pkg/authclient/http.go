@@ -0,0 +1,65 @@
+package authclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/ljinf/user_auth/common/util"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// httpVerifier 通过认证服务的网关转发认证接口 GET /auth/verify 验证Token
+type httpVerifier struct {
+	verifyUrl  string
+	httpClient *http.Client
+}
+
+// NewHTTPVerifier baseUrl 为认证服务的地址, 例如 http://user-auth:8080, httpClient 为nil时使用 http.DefaultClient
+func NewHTTPVerifier(baseUrl string, httpClient *http.Client) Verifier {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &httpVerifier{
+		verifyUrl:  strings.TrimRight(baseUrl, "/") + "/auth/verify",
+		httpClient: httpClient,
+	}
+}
+
+func (hv *httpVerifier) Verify(ctx context.Context, token string) (*Identity, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hv.verifyUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	// 在Header中添加追踪信息 把内部服务串起来
+	traceId, spanId, _ := util.GetTraceInfoFromCtx(ctx)
+	req.Header.Set("traceid", traceId)
+	req.Header.Set("spanid", spanId)
+	resp, err := hv.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, ErrInvalidToken
+	default:
+		return nil, fmt.Errorf("authclient: unexpected response code %d", resp.StatusCode)
+	}
+	userId, err := strconv.ParseInt(resp.Header.Get("X-User-Id"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("authclient: invalid X-User-Id: %w", err)
+	}
+	return &Identity{
+		PrincipalType: resp.Header.Get("X-Principal-Type"),
+		UserId:        userId,
+		SessionId:     resp.Header.Get("X-Session-Id"),
+		Platform:      resp.Header.Get("X-Platform"),
+		Scope:         resp.Header.Get("X-Scope"),
+	}, nil
+}
@@ -0,0 +1,66 @@
+package authclient
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/ljinf/user_auth/common/errcode"
+	"net/http"
+	"strings"
+)
+
+// GinMiddleware 与认证服务的 middleware.AuthUser 对应的Gin中间件
+// 认证通过后在Context中写入 userId、sessionId 和 platform
+func (c *Client) GinMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		identity, err := c.Verify(ctx, ExtractToken(ctx.Request))
+		if err != nil {
+			status, appErr := errorResponse(err)
+			ctx.AbortWithStatusJSON(status, gin.H{"code": appErr.Code(), "msg": appErr.Msg()})
+			return
+		}
+		ctx.Set("userId", identity.UserId)
+		ctx.Set("sessionId", identity.SessionId)
+		ctx.Set("platform", identity.Platform)
+		ctx.Next()
+	}
+}
+
+// HTTPMiddleware net/http 使用的中间件, 认证通过后可以通过 IdentityFromContext 获取用户身份
+func (c *Client) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, err := c.Verify(r.Context(), ExtractToken(r))
+		if err != nil {
+			status, appErr := errorResponse(err)
+			http.Error(w, appErr.Msg(), status)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, identity)))
+	})
+}
+
+type identityKey struct{}
+
+// IdentityFromContext 获取 HTTPMiddleware 写入的用户身份
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok
+}
+
+// ExtractToken 从 Authorization: Bearer 或者 go-mall-token 请求头中读取Token
+func ExtractToken(r *http.Request) string {
+	authorization := r.Header.Get("Authorization")
+	prefix := enum.TokenTypeBearer + " "
+	if len(authorization) > len(prefix) && strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return strings.TrimSpace(authorization[len(prefix):])
+	}
+	return r.Header.Get("go-mall-token")
+}
+
+func errorResponse(err error) (int, *errcode.AppError) {
+	if errors.Is(err, ErrInvalidToken) {
+		return http.StatusUnauthorized, errcode.ErrToken
+	}
+	return http.StatusInternalServerError, errcode.ErrServer
+}
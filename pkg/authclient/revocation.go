@@ -0,0 +1,41 @@
+package authclient
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ljinf/user_auth/common/enum"
+	"github.com/redis/go-redis/v9"
+)
+
+// revokedEvent 认证服务发布的会话吊销事件, SessionId 为空表示用户的全部会话都被吊销
+type revokedEvent struct {
+	UserId    int64  `json:"user_id"`
+	SessionId string `json:"session_id"`
+}
+
+// SubscribeRevocations 订阅认证服务的会话吊销事件, 收到事件后立即清理对应的缓存
+// rdb 需要连接认证服务使用的Redis, 函数会阻塞到ctx结束, 一般在单独的goroutine中调用
+func (c *Client) SubscribeRevocations(ctx context.Context, rdb redis.UniversalClient) error {
+	pubsub := rdb.Subscribe(ctx, enum.REDIS_CHANNEL_SESSION_REVOKED)
+	defer pubsub.Close()
+	// 等待订阅成功, 连接失败时直接返回错误
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			event := new(revokedEvent)
+			if err := json.Unmarshal([]byte(msg.Payload), event); err != nil || event.UserId == 0 {
+				continue
+			}
+			c.Revoke(event.UserId, event.SessionId)
+		}
+	}
+}